@@ -6,6 +6,7 @@ import (
 	"github.com/libanvl/swager/internal/core"
 	"github.com/libanvl/swager/internal/core/node"
 	"github.com/libanvl/swager/ipc"
+	"github.com/libanvl/swager/ipc/command"
 	"github.com/libanvl/swager/stoker"
 )
 
@@ -13,6 +14,7 @@ type LayoutEngine func(evt ipc.WindowChange, ws *ipc.Node) error
 
 type Autolay struct {
 	core.BasicBlock
+	state        *core.State
 	workspaces   map[string]LayoutEngine
 	workspacesmx sync.Mutex
 	eventmx      sync.Mutex
@@ -57,7 +59,19 @@ func (a *Autolay) Init(client core.Client, sub core.Sub, opts *core.Options, log
 		return err
 	}
 
-	if _, err := sub.WindowChanges(a.WindowChanged); err != nil {
+	a.state = opts.State
+	if a.state == nil {
+		a.state = core.NewState(client)
+		if err := a.state.Attach(sub); err != nil {
+			return err
+		}
+	}
+
+	// title changes are frequent and never change the layout
+	if _, err := a.state.WindowChangesFiltered(a.WindowChanged,
+		node.MatchNot(node.MatchType(ipc.FloatingConNode)),
+		ipc.NewWindow, ipc.CloseWindow, ipc.FocusWindow, ipc.FullscreenModeWindow,
+		ipc.MoveWindow, ipc.FloatingWindow, ipc.UrgentWindow, ipc.MarkWindow); err != nil {
 		return err
 	}
 
@@ -73,50 +87,40 @@ func (a *Autolay) WindowChanged(evt ipc.WindowChange) {
 		return
 	}
 
-	workspaces, err := a.Client.Workspaces()
+	a.eventmx.Lock()
+	defer a.eventmx.Unlock()
+
+	workspace_node, err := a.state.FocusedWorkspace()
 	if err != nil {
-		a.Log.Defaultf("(%v) Failed getting workspaces", evt.Container.ID)
+		a.Log.Defaultf("(%v) Failed getting tree: %#v", evt.Container.ID, err)
 		return
 	}
 
-	a.eventmx.Lock()
-	defer a.eventmx.Unlock()
-
-	focused := core.Focused(workspaces)
-	if focused == nil {
+	if workspace_node == nil {
 		a.Log.Defaultf("(%v) Failed finding focused workspace", evt.Container.ID)
 		return
 	}
 
-	eng, ok := a.workspaces[focused.Name]
+	eng, ok := a.workspaces[workspace_node.Name]
 	if !ok {
-		a.Log.Debugf("(%v) Parent not managed: %v", evt.Container.ID, focused.Name)
+		a.Log.Debugf("(%v) Parent not managed: %v", evt.Container.ID, workspace_node.Name)
 		return
 	}
 
 	a.Log.Debugf("(%v) Using engine: %#v", evt.Container.ID, eng)
 
-	root, err := a.Client.Tree()
-	if err != nil {
-		a.Log.Defaultf("(%v) Failed getting tree: %#v", evt.Container.ID, err)
-	}
-
-	workspace_node := node.First(
-		root,
-		node.MatchAnd(
-			node.MatchType(ipc.WorkspaceNode),
-			node.MatchName(focused.Name)))
-
 	err = eng(evt, workspace_node)
 	if err != nil {
-		a.Log.Defaultf("(%v) Error executing step: %#v", err)
+		a.Log.Defaultf("(%v) Error executing step: %v", evt.Container.ID, err)
 	}
 }
 
-func (a *Autolay) Command(engine_name string, cmd string) error {
+func (a *Autolay) Command(engine_name string, cmd command.Command) error {
 	a.Log.Debugf("{%v} running command: %v", engine_name, cmd)
 
-	res, err := a.Client.Command(cmd)
+	res, err := a.Client.Command(cmd.String())
+	// sway sends no event for split and layout changes
+	a.state.Invalidate()
 	if err != nil {
 		a.Log.Defaultf("{%v} ipc error: %#v", engine_name, err)
 		return err
@@ -128,6 +132,11 @@ func (a *Autolay) Command(engine_name string, cmd string) error {
 		}
 	}
 
+	if err := ipc.CheckCommand(cmd.String(), res); err != nil {
+		a.Log.Defaultf("{%v} %v", engine_name, err)
+		return err
+	}
+
 	return nil
 }
 
@@ -4,6 +4,7 @@ import (
 	"github.com/libanvl/swager/internal/core"
 	"github.com/libanvl/swager/internal/core/node"
 	"github.com/libanvl/swager/ipc"
+	"github.com/libanvl/swager/ipc/command"
 )
 
 func (a *Autolay) autoTiler(evt ipc.WindowChange, ws *ipc.Node) error {
@@ -26,11 +27,10 @@ func (a *Autolay) autoTiler(evt ipc.WindowChange, ws *ipc.Node) error {
 	is_even := (cwin % 2) == 0
 
 	if is_even {
-		a.Command("autotiler", "splitv")
-	} else {
-		a.Command("autotiler", "splith")
+		return a.Command("autotiler", command.Split(command.Vertical))
 	}
-	return nil
+
+	return a.Command("autotiler", command.Split(command.Horizontal))
 }
 
 func (a *Autolay) masterStack(evt ipc.WindowChange, ws *ipc.Node) error {
@@ -52,11 +52,13 @@ func (a *Autolay) masterStack(evt ipc.WindowChange, ws *ipc.Node) error {
 			node.MatchType(ipc.ConNode)))
 	switch {
 	case cwin == 1:
-		a.Command("masterstack", "splith")
+		return a.Command("masterstack", command.Split(command.Horizontal))
 	case cwin == 2:
-		a.Command("masterstack", "splitv")
+		return a.Command("masterstack", command.Split(command.Vertical))
 	case cwin == 3:
-		a.Command("masterstack", "focus parent; splitv, focus child")
+		return a.Command("masterstack", command.FocusParent().
+			Then(command.Split(command.Vertical)).
+			And(command.FocusChild()))
 	}
 
 	return nil
@@ -0,0 +1,138 @@
+package blocks_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/libanvl/swager/blocks"
+	"github.com/libanvl/swager/internal/core"
+	"github.com/libanvl/swager/ipc"
+	"github.com/libanvl/swager/test/swaytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// autolayHarness runs an Autolay block against a simulated sway tree.
+// Events are handed to the tree state of the block one at a time, so each
+// step settles before the next, unlike a Subscription which runs handlers
+// concurrently.
+type autolayHarness struct {
+	tree   *swaytest.Tree
+	block  *blocks.Autolay
+	state  *core.State
+	server *swaytest.Server
+	events chan any
+}
+
+func newAutolayHarness(t *testing.T, args ...string) *autolayHarness {
+	server := swaytest.NewServer(t)
+	h := &autolayHarness{
+		tree:   swaytest.NewTree("eDP-1"),
+		block:  new(blocks.Autolay),
+		server: server,
+		events: make(chan any, 100),
+	}
+
+	server.Simulate(h.tree)
+
+	client, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+	require.Nil(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	sc, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+	require.Nil(t, err)
+	sub := ipc.SubscribeCustom(sc)
+	t.Cleanup(func() { sub.Close() })
+
+	h.state = core.NewState(client)
+	logch := make(chan core.LogMessage, 1000)
+	err = h.block.Init(client, sub, &core.Options{State: h.state}, core.NewPrefixLogger("autolay", logch), args...)
+	require.Nil(t, err)
+
+	h.tree.OnEvent(func(ept ipc.EventPayloadType, args any) {
+		h.events <- args
+	})
+
+	return h
+}
+
+func (h *autolayHarness) settle() {
+	for {
+		select {
+		case evt := <-h.events:
+			switch e := evt.(type) {
+			case ipc.WindowChange:
+				h.state.WindowChanged(e)
+			case ipc.WorkspaceChange:
+				h.state.WorkspaceChanged(e)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (h *autolayHarness) open(app string) int {
+	id := h.tree.OpenWindow(app)
+	h.settle()
+	return id
+}
+
+func (h *autolayHarness) close(t *testing.T, id int) {
+	require.Nil(t, h.tree.CloseWindow(id))
+	h.settle()
+}
+
+func TestAutolayAutoTiler(t *testing.T) {
+	h := newAutolayHarness(t, "-autotiler", "1")
+
+	a := h.open("a")
+	assert.Equal(t, "H[a]", h.tree.Shape("1"))
+
+	h.open("b")
+	assert.Equal(t, "H[a V[b]]", h.tree.Shape("1"))
+
+	h.open("c")
+	assert.Equal(t, "H[a V[b H[c]]]", h.tree.Shape("1"))
+
+	h.open("d")
+	assert.Equal(t, "H[a V[b H[c V[d]]]]", h.tree.Shape("1"))
+
+	// focus falls back to d, which now flips to the odd split
+	h.close(t, a)
+	assert.Equal(t, "H[V[b H[c H[d]]]]", h.tree.Shape("1"))
+
+	// the focused workspace comes from the tree state
+	for _, m := range h.server.Received() {
+		assert.NotEqual(t, ipc.GetWorkspacesMessage, m.Type)
+	}
+}
+
+func TestAutolayMasterStack(t *testing.T) {
+	h := newAutolayHarness(t, "-masterstack", "1")
+
+	h.open("a")
+	assert.Equal(t, "H[a]", h.tree.Shape("1"))
+
+	h.open("b")
+	assert.Equal(t, "H[a V[b]]", h.tree.Shape("1"))
+
+	// the third window splits the stack again from its parent
+	c := h.open("c")
+	assert.Equal(t, "H[a V[V[b c]]]", h.tree.Shape("1"))
+
+	h.open("d")
+	assert.Equal(t, "H[a V[V[b c d]]]", h.tree.Shape("1"))
+
+	h.close(t, c)
+	assert.Equal(t, "H[a V[V[b d]]]", h.tree.Shape("1"))
+}
+
+func TestAutolayUnmanagedWorkspace(t *testing.T) {
+	h := newAutolayHarness(t, "-autotiler", "2")
+
+	h.open("a")
+	h.open("b")
+	h.open("c")
+	assert.Equal(t, "H[a b c]", h.tree.Shape("1"))
+}
@@ -2,11 +2,11 @@ package blocks
 
 import (
 	"errors"
-	"fmt"
 	"strconv"
 	"strings"
 
 	"github.com/libanvl/swager/internal/core"
+	"github.com/libanvl/swager/ipc/command"
 )
 
 type ExecNew struct {
@@ -75,17 +75,6 @@ func (e *ExecNew) Receive(args []string) error {
 
 	e.log.Debugf("running command on workspace: %d, '%s'", next, cmd)
 
-	res, err := e.client.Command(fmt.Sprintf("workspace number %d, exec %s", next, cmd))
-	if err != nil {
-		return err
-	}
-
-	for _, r := range res {
-		e.log.Debugf("result: %#v", r)
-		if !r.Success {
-			return errors.New(r.Error)
-		}
-	}
-
-	return nil
+	return e.client.CommandChecked(
+		command.WorkspaceNumber(next).And(command.Exec(cmd)).String())
 }
@@ -31,7 +31,7 @@ func (i *InitSpawn) Init(client core.Client, sub core.Sub, opts *core.Options, l
 	i.log = log
 	i.spawns = map[workspace]string{}
 	i.spawnsmx = sync.Mutex{}
-	cookie, err := sub.WorkspaceChanges(i.WorkspaceChanged)
+	cookie, err := sub.WorkspaceChangesFiltered(i.WorkspaceChanged, nil, ipc.InitWorkspace)
 	if err != nil {
 		return err
 	}
@@ -66,16 +66,9 @@ func (i *InitSpawn) WorkspaceChanged(evt ipc.WorkspaceChange) {
 
 		i.log.Defaultf("running spawn command: '%s'", cmd)
 
-		res, err := i.client.Command(cmd)
-		if err != nil {
+		if err := i.client.CommandChecked(cmd); err != nil {
 			i.log.Default(err.Error())
 		}
-
-		for _, r := range res {
-			if !r.Success {
-				i.log.Default(r.Error)
-			}
-		}
 	}
 }
 
@@ -25,7 +25,7 @@ func (m *SwayMon) Init(client core.Client, sub core.Sub, opts *core.Options, log
 		return err
 	}
 
-	_, err = m.sub.WorkspaceChanges(m.WorkspaceChanged)
+	_, err = m.sub.WorkspaceChangesFiltered(m.WorkspaceChanged, nil, ipc.ReloadWorkspace)
 	if err != nil {
 		return err
 	}
@@ -15,9 +15,11 @@ import (
 )
 
 var loglevel core.LogLevel
+var recordpath string
 
 func main() {
 	flag.Var(&loglevel, "log", "the log level")
+	flag.StringVar(&recordpath, "record", "", "record the sway-ipc session to a JSONL file")
 	flag.Parse()
 
 	log.Println("log level: ", loglevel)
@@ -46,6 +48,17 @@ func main() {
 		Log:    logch,
 	}
 
+	if recordpath != "" {
+		f, err := os.Create(recordpath)
+		if err != nil {
+			log.Fatal("failed creating recording:", err)
+		}
+		defer f.Close()
+
+		config.Record = f
+		log.Println("recording to:", recordpath)
+	}
+
 	server, err := comm.CreateServer(&config, &opts)
 	if err != nil {
 		log.Fatal("failed creating server:", err)
@@ -1,24 +1,39 @@
 package comm
 
 import (
+	"context"
+	"encoding/binary"
 	"io"
+	"time"
 
 	"github.com/libanvl/swager/internal/core"
 	"github.com/libanvl/swager/ipc"
+	"github.com/libanvl/swager/ipc/record"
 )
 
+// clientTimeout bounds every message the shared Client sends,
+// so a hung sway cannot block the blocks forever.
+const clientTimeout = 5 * time.Second
+
+// subDelivery hands every block its events one at a time, in order.
+var subDelivery = ipc.Delivery{Mode: ipc.OrderedDelivery, Buffer: 64, Policy: ipc.BlockPolicy}
+
 type Swager struct {
 	Client     *ipc.Client
 	Sub        *ipc.Subscription
 	cfg        *ServerConfig
 	opts       *core.Options
 	initalized map[string]core.BlockInitializer
+	recorder   *record.Recorder
+	running    chan error
 }
 
 type ServerConfig struct {
 	Blocks core.BlockRegistry
 	Ctrl   chan<- *ControlArgs
 	Log    core.LogChannel
+	// Record receives a recording of the sway-ipc session when set.
+	Record io.Writer
 }
 
 func CreateServer(cfg *ServerConfig, opts *core.Options) (*Swager, error) {
@@ -26,11 +41,30 @@ func CreateServer(cfg *ServerConfig, opts *core.Options) (*Swager, error) {
 	if err != nil {
 		return nil, err
 	}
+	client.SetTimeout(clientTimeout)
+	client.SetReconnect(ipc.DefaultBackoff)
 
 	sub, err := ipc.Subscribe()
 	if err != nil {
+		client.Close()
 		return nil, err
 	}
+	sub.SetReconnect(ipc.DefaultBackoff)
+	sub.SetDelivery(subDelivery)
+
+	swager := new(Swager)
+	swager.Client = client
+	swager.Sub = sub
+	swager.opts = opts
+	swager.cfg = cfg
+
+	// wrap before Attach, so the recording holds its subscribe exchange
+	if cfg.Record != nil {
+		// ipc.Connect always uses LittleEndian
+		swager.recorder = record.NewRecorder(cfg.Record, binary.LittleEndian)
+		client.WrapConn(swager.recorder.Wrap)
+		sub.WrapConn(swager.recorder.Wrap)
+	}
 
 	suberrors := make(chan error, 3)
 	go func() {
@@ -41,11 +75,12 @@ func CreateServer(cfg *ServerConfig, opts *core.Options) (*Swager, error) {
 
 	sub.Errors(suberrors)
 
-	swager := new(Swager)
-	swager.Client = client
-	swager.Sub = sub
-	swager.opts = opts
-	swager.cfg = cfg
+	opts.State = core.NewState(client)
+	if err := opts.State.Attach(sub); err != nil {
+		sub.Close()
+		client.Close()
+		return nil, err
+	}
 
 	return swager, nil
 }
@@ -123,25 +158,36 @@ func (s *Swager) Control(args *ControlArgs, reply *Reply) error {
 				go runner.Run()
 			}
 		}
-		go s.Sub.Run()
+		s.runSub()
 		reply.Args = args
 		reply.Success = true
 		return nil
 	case ResetServer:
 		s.cfg.Log.Send(core.DefaultLog, "server", "resetting initalized blocks")
 		closeAllBlocks(s)
-		s.Sub.Close()
+		s.stopSub()
 		sub, err := ipc.Subscribe()
 		if err != nil {
 			return err
 		}
+		sub.SetReconnect(ipc.DefaultBackoff)
+		sub.SetDelivery(subDelivery)
+		if s.recorder != nil {
+			sub.WrapConn(s.recorder.Wrap)
+		}
+		state := core.NewState(s.Client)
+		if err := state.Attach(sub); err != nil {
+			sub.Close()
+			return err
+		}
+		s.opts.State = state
 		s.Sub = sub
 		reply.Args = args
 		reply.Success = true
 		return nil
 	case ExitServer:
 		closeAllBlocks(s)
-		s.Sub.Close()
+		s.stopSub()
 		fallthrough
 	default:
 		s.cfg.Ctrl <- args
@@ -152,6 +198,37 @@ func (s *Swager) Control(args *ControlArgs, reply *Reply) error {
 	return nil
 }
 
+// runSub runs the Subscription, unless it is running already.
+func (s *Swager) runSub() {
+	if s.running != nil {
+		select {
+		case err := <-s.running:
+			s.cfg.Log.Sendf(core.InfoLog, "server", "subscription stopped: %s", err)
+		default:
+			return
+		}
+	}
+
+	running := make(chan error, 1)
+	s.running = running
+	go func(sub *ipc.Subscription) {
+		running <- sub.RunCtx(context.Background())
+	}(s.Sub)
+}
+
+// stopSub closes the Subscription and waits for its Run to return,
+// so a new Subscription never overlaps with the old one.
+func (s *Swager) stopSub() {
+	s.Sub.Close()
+	if s.running == nil {
+		return
+	}
+
+	err := <-s.running
+	s.running = nil
+	s.cfg.Log.Sendf(core.InfoLog, "server", "subscription stopped: %s", err)
+}
+
 func closeAllBlocks(s *Swager) {
 	for tag, block := range s.initalized {
 		closer, ok := block.(io.Closer)
@@ -0,0 +1,100 @@
+package comm_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/libanvl/swager/blocks"
+	"github.com/libanvl/swager/internal/comm"
+	"github.com/libanvl/swager/internal/core"
+	"github.com/libanvl/swager/ipc"
+	"github.com/libanvl/swager/ipc/record"
+	"github.com/libanvl/swager/test/swaytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerExecNew(t *testing.T) {
+	sway := swaytest.NewServer(t)
+	sway.Setenv()
+	sway.Reply(ipc.GetWorkspacesMessage, []ipc.Workspace{{Num: 1}, {Num: 3}, {Num: 9}})
+
+	registry := make(core.BlockRegistry)
+	registry.Register("execnew", func() core.BlockInitializer { return new(blocks.ExecNew) })
+
+	logch := make(chan core.LogMessage, 100)
+	server, err := comm.CreateServer(
+		&comm.ServerConfig{Blocks: registry, Log: logch},
+		&core.Options{})
+	require.Nil(t, err)
+
+	var reply comm.Reply
+	err = server.InitBlock(&comm.InitBlockArgs{Tag: "newws", Block: "execnew", Args: []string{"3", "7"}}, &reply)
+	require.Nil(t, err)
+	assert.True(t, reply.Success)
+
+	err = server.SendToTag(&comm.SendToTagArgs{Tag: "newws", Args: []string{"foot", "-e", "htop"}}, &reply)
+	require.Nil(t, err)
+
+	assert.Equal(t, []string{"workspace number 4, exec foot -e htop"}, sway.Commands())
+}
+
+func TestServerUnknownBlock(t *testing.T) {
+	sway := swaytest.NewServer(t)
+	sway.Setenv()
+
+	server, err := comm.CreateServer(
+		&comm.ServerConfig{Blocks: make(core.BlockRegistry), Log: make(chan core.LogMessage, 10)},
+		&core.Options{})
+	require.Nil(t, err)
+
+	var reply comm.Reply
+	err = server.InitBlock(&comm.InitBlockArgs{Tag: "x", Block: "missing"}, &reply)
+	var notFound *comm.BlockNotFoundError
+	assert.ErrorAs(t, err, &notFound)
+}
+
+func TestServerResetStopsSubscription(t *testing.T) {
+	sway := swaytest.NewServer(t)
+	sway.Setenv()
+
+	logch := make(chan core.LogMessage, 100)
+	server, err := comm.CreateServer(
+		&comm.ServerConfig{Blocks: make(core.BlockRegistry), Log: logch},
+		&core.Options{})
+	require.Nil(t, err)
+
+	var reply comm.Reply
+	require.Nil(t, server.Control(&comm.ControlArgs{Command: comm.RunServer}, &reply))
+	old := server.Sub
+
+	require.Nil(t, server.Control(&comm.ControlArgs{Command: comm.ResetServer}, &reply))
+	assert.True(t, reply.Success)
+	assert.NotSame(t, old, server.Sub)
+
+	_, err = old.Ticks(func(ipc.Tick) {})
+	assert.NotNil(t, err)
+
+	require.Nil(t, server.Control(&comm.ControlArgs{Command: comm.RunServer}, &reply))
+	require.Nil(t, server.Control(&comm.ControlArgs{Command: comm.ResetServer}, &reply))
+	assert.True(t, reply.Success)
+}
+
+func TestServerRecordsSubscribe(t *testing.T) {
+	sway := swaytest.NewServer(t)
+	sway.Setenv()
+
+	var rec bytes.Buffer
+	server, err := comm.CreateServer(
+		&comm.ServerConfig{Blocks: make(core.BlockRegistry), Log: make(chan core.LogMessage, 10), Record: &rec},
+		&core.Options{})
+	require.Nil(t, err)
+	defer server.Sub.Close()
+
+	entries, err := record.Load(&rec)
+	require.Nil(t, err)
+	require.NotEmpty(t, entries)
+	assert.Equal(t, record.Request, entries[0].Direction)
+	assert.Equal(t, ipc.SubscribeMessage, entries[0].Type)
+	assert.Equal(t, record.Reply, entries[1].Direction)
+}
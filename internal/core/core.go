@@ -1,6 +1,8 @@
 package core
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 
 	"github.com/libanvl/swager/ipc"
@@ -18,23 +20,50 @@ func init() {
 // Client exports a limited set of methods for use by core.Block instances.
 type Client interface {
 	Command(cmd string) ([]ipc.Command, error)
+	CommandCtx(ctx context.Context, cmd string) ([]ipc.Command, error)
+	CommandChecked(cmd string) error
 	CommandRaw(cmd string) (string, error)
 	Workspaces() ([]ipc.Workspace, error)
+	WorkspacesCtx(ctx context.Context) ([]ipc.Workspace, error)
 	WorkspacesRaw() (string, error)
 	Tree() (*ipc.Node, error)
+	TreeCtx(ctx context.Context) (*ipc.Node, error)
 	TreeRaw() (string, error)
+	TreeWhere(pred func(*ipc.Node) bool) ([]*ipc.Node, error)
+	TreeWhereCtx(ctx context.Context, pred func(*ipc.Node) bool) ([]*ipc.Node, error)
+	FocusedWorkspace() (*ipc.Node, error)
+	FocusedWorkspaceCtx(ctx context.Context) (*ipc.Node, error)
 	Version() (*ipc.Version, error)
+	VersionCtx(ctx context.Context) (*ipc.Version, error)
 	VersionRaw() (string, error)
+	Inputs() ([]ipc.Input, error)
+	InputsRaw() (string, error)
+	Seats() ([]ipc.Seat, error)
+	SeatsRaw() (string, error)
+	Config() (string, error)
+	ConfigRaw() (string, error)
+	Sync() (*ipc.Result, error)
+	SyncRaw() (string, error)
 }
 
 // Sub exports a limited set of methods for use by core.Block instances.
 type Sub interface {
 	WorkspaceChanges(func(ipc.WorkspaceChange)) (ipc.Cookie, error)
+	WorkspaceChangesFiltered(func(ipc.WorkspaceChange), func(*ipc.Node) bool, ...ipc.WorkspaceChangeType) (ipc.Cookie, error)
+	OutputChanges(func(ipc.OutputChange)) (ipc.Cookie, error)
 	WindowChanges(func(ipc.WindowChange)) (ipc.Cookie, error)
+	WindowChangesFiltered(func(ipc.WindowChange), func(*ipc.Node) bool, ...ipc.WindowChangeType) (ipc.Cookie, error)
 	BindingChanges(func(ipc.BindingChange)) (ipc.Cookie, error)
 	ModeChanges(func(ipc.ModeChange)) (ipc.Cookie, error)
 	ShutdownChanges(func(ipc.ShutdownChange)) (ipc.Cookie, error)
 	Ticks(func(ipc.Tick)) (ipc.Cookie, error)
+	BarConfigUpdates(func(ipc.BarConfigUpdate)) (ipc.Cookie, error)
+	BarStateUpdates(func(ipc.BarStateUpdate)) (ipc.Cookie, error)
+	InputChanges(func(ipc.InputChange)) (ipc.Cookie, error)
+	Reconnects(func(ipc.Reconnected)) (ipc.Cookie, error)
+	RawEvents([]ipc.EventPayloadType, func(ipc.EventPayloadType, json.RawMessage)) (ipc.Cookie, error)
+	Events(ctx context.Context, types ...ipc.EventPayloadType) (<-chan ipc.Event, error)
+	Enveloped([]ipc.EventPayloadType, func(ipc.Event)) (ipc.Cookie, error)
 }
 
 type ServerControlRequest int8
@@ -62,4 +91,6 @@ func (scc ServerControlChannel) RequestExit() {
 // Use the Log channel to send log data back to the daemon.
 type Options struct {
 	Server ServerControlChannel
+	// State is the tree state shared by the blocks, if any.
+	State *State
 }
@@ -0,0 +1,188 @@
+package node
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/libanvl/swager/ipc"
+)
+
+// ChangeKind tells how a container differs between two trees.
+//
+//go:generate go run golang.org/x/tools/cmd/stringer -type=ChangeKind
+type ChangeKind uint8
+
+const (
+	// Added containers are only in the new tree.
+	Added ChangeKind = iota
+	// Removed containers are only in the old tree.
+	Removed
+	// Moved containers have another parent or workspace.
+	Moved
+	// Relayout containers have another Layout or Orientation.
+	Relayout
+	// Resized containers have another Rect.
+	Resized
+	// MarksChanged containers have other Marks.
+	MarksChanged
+	// FocusChanged containers gained or lost the focus.
+	FocusChanged
+	// FullscreenChanged containers have another FullscreenMode.
+	FullscreenChanged
+)
+
+// Change is a single difference between two trees.
+// A container that changed in several ways has a Change for each.
+type Change struct {
+	Kind ChangeKind
+	ID   int
+	// Before is the container in the old tree, nil when Added.
+	Before *ipc.Node
+	// After is the container in the new tree, nil when Removed.
+	After *ipc.Node
+	// FromParent and ToParent are the ids of the parents of a Moved
+	// container, FromWorkspace and ToWorkspace the names of its workspaces.
+	FromParent, ToParent       int
+	FromWorkspace, ToWorkspace string
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("added %v %d", c.After.Type, c.ID)
+	case Removed:
+		return fmt.Sprintf("removed %v %d", c.Before.Type, c.ID)
+	case Moved:
+		return fmt.Sprintf("moved %d from %d (%s) to %d (%s)",
+			c.ID, c.FromParent, c.FromWorkspace, c.ToParent, c.ToWorkspace)
+	case Relayout:
+		return fmt.Sprintf("relayout %d: %s/%s -> %s/%s", c.ID,
+			c.Before.Layout, c.Before.Orientation, c.After.Layout, c.After.Orientation)
+	case Resized:
+		return fmt.Sprintf("resized %d: %+v -> %+v", c.ID, c.Before.Rect, c.After.Rect)
+	case MarksChanged:
+		return fmt.Sprintf("marks %d: %v -> %v", c.ID, c.Before.Marks, c.After.Marks)
+	case FocusChanged:
+		return fmt.Sprintf("focus %d: %v -> %v", c.ID, c.Before.Focused, c.After.Focused)
+	case FullscreenChanged:
+		return fmt.Sprintf("fullscreen %d: %v -> %v", c.ID,
+			fullscreenMode(c.Before), fullscreenMode(c.After))
+	}
+
+	return fmt.Sprintf("%v %d", c.Kind, c.ID)
+}
+
+// Diff compares two trees and returns how before became after.
+// Changes of containers in after come first, in tree order,
+// followed by the removed containers, in the order of before.
+// Either tree may be nil.
+func Diff(before *ipc.Node, after *ipc.Node) []Change {
+	old := make(map[int]placed)
+	var oldOrder []int
+	walkPlaced(before, placed{}, func(p placed) {
+		old[p.n.ID] = p
+		oldOrder = append(oldOrder, p.n.ID)
+	})
+
+	var changes []Change
+	seen := make(map[int]bool)
+	walkPlaced(after, placed{}, func(p placed) {
+		seen[p.n.ID] = true
+		o, ok := old[p.n.ID]
+		if !ok {
+			changes = append(changes, Change{Kind: Added, ID: p.n.ID, After: p.n})
+			return
+		}
+
+		changes = append(changes, compare(o, p)...)
+	})
+
+	for _, id := range oldOrder {
+		if !seen[id] {
+			changes = append(changes, Change{Kind: Removed, ID: id, Before: old[id].n})
+		}
+	}
+
+	return changes
+}
+
+// placed is a container with where it is in its tree.
+type placed struct {
+	n         *ipc.Node
+	parent    int
+	workspace string
+}
+
+func walkPlaced(n *ipc.Node, at placed, f func(placed)) {
+	if n == nil {
+		return
+	}
+
+	at.n = n
+	f(at)
+
+	inner := placed{parent: n.ID, workspace: at.workspace}
+	if n.Type == ipc.WorkspaceNode {
+		inner.workspace = n.Name
+	}
+
+	for _, c := range n.Nodes {
+		walkPlaced(c, inner, f)
+	}
+
+	for _, c := range n.FloatingNodes {
+		walkPlaced(c, inner, f)
+	}
+}
+
+func compare(o placed, p placed) []Change {
+	var changes []Change
+	change := func(kind ChangeKind) Change {
+		return Change{Kind: kind, ID: p.n.ID, Before: o.n, After: p.n}
+	}
+
+	if o.parent != p.parent || o.workspace != p.workspace {
+		c := change(Moved)
+		c.FromParent, c.ToParent = o.parent, p.parent
+		c.FromWorkspace, c.ToWorkspace = o.workspace, p.workspace
+		changes = append(changes, c)
+	}
+
+	if o.n.Layout != p.n.Layout || o.n.Orientation != p.n.Orientation {
+		changes = append(changes, change(Relayout))
+	}
+
+	if o.n.Rect != p.n.Rect {
+		changes = append(changes, change(Resized))
+	}
+
+	if !sameMarks(o.n.Marks, p.n.Marks) {
+		changes = append(changes, change(MarksChanged))
+	}
+
+	if o.n.Focused != p.n.Focused {
+		changes = append(changes, change(FocusChanged))
+	}
+
+	if fullscreenMode(o.n) != fullscreenMode(p.n) {
+		changes = append(changes, change(FullscreenChanged))
+	}
+
+	return changes
+}
+
+func sameMarks(a []string, b []string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func fullscreenMode(n *ipc.Node) ipc.FullscreenModeType {
+	if n.FullscreenMode == nil {
+		return ipc.NoneFullscreenMode
+	}
+
+	return *n.FullscreenMode
+}
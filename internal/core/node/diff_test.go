@@ -0,0 +1,108 @@
+package node_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/libanvl/swager/internal/core/node"
+	"github.com/libanvl/swager/ipc"
+	"github.com/libanvl/swager/test/swaytest"
+	"github.com/stretchr/testify/assert"
+)
+
+type kindID struct {
+	Kind node.ChangeKind
+	ID   int
+}
+
+func kinds(changes []node.Change) []kindID {
+	var got []kindID
+	for _, c := range changes {
+		got = append(got, kindID{c.Kind, c.ID})
+	}
+
+	return got
+}
+
+func TestDiffCommands(t *testing.T) {
+	tests := map[string]func(tree *swaytest.Tree, a, b int) []kindID{
+		"open": func(tree *swaytest.Tree, a, b int) []kindID {
+			c := tree.OpenWindow("c")
+			return []kindID{{node.FocusChanged, b}, {node.Added, c}}
+		},
+		"close": func(tree *swaytest.Tree, a, b int) []kindID {
+			tree.CloseWindow(b)
+			return []kindID{{node.FocusChanged, a}, {node.Removed, b}}
+		},
+		"focus": func(tree *swaytest.Tree, a, b int) []kindID {
+			tree.Apply("[con_id=" + strconv.Itoa(a) + "] focus")
+			return []kindID{{node.FocusChanged, a}, {node.FocusChanged, b}}
+		},
+		"mark": func(tree *swaytest.Tree, a, b int) []kindID {
+			tree.Apply("[con_id=" + strconv.Itoa(a) + "] mark x")
+			return []kindID{{node.MarksChanged, a}}
+		},
+		"layout": func(tree *swaytest.Tree, a, b int) []kindID {
+			ws := tree.Root().Nodes[0].Nodes[0].ID
+			tree.Apply("layout splitv")
+			return []kindID{{node.Relayout, ws}}
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tree := swaytest.NewTree("eDP-1")
+			a := tree.OpenWindow("a")
+			b := tree.OpenWindow("b")
+
+			before := tree.Root()
+			expected := tc(tree, a, b)
+			assert.Equal(t, expected, kinds(node.Diff(before, tree.Root())))
+		})
+	}
+}
+
+func TestDiffMoved(t *testing.T) {
+	tree := swaytest.NewTree("eDP-1")
+	a := tree.OpenWindow("a")
+	tree.OpenWindow("b")
+	before := tree.Root()
+
+	tree.Apply("[con_id=" + strconv.Itoa(a) + "] move to workspace 2")
+
+	var moved *node.Change
+	for _, c := range node.Diff(before, tree.Root()) {
+		if c.Kind == node.Moved {
+			c := c
+			moved = &c
+		}
+	}
+
+	if assert.NotNil(t, moved) {
+		assert.Equal(t, a, moved.ID)
+		assert.Equal(t, "1", moved.FromWorkspace)
+		assert.Equal(t, "2", moved.ToWorkspace)
+		assert.NotEqual(t, moved.FromParent, moved.ToParent)
+		assert.Contains(t, moved.String(), "(1)")
+	}
+}
+
+func TestDiffNodes(t *testing.T) {
+	full := ipc.GlobalFullscreenMode
+	before := &ipc.Node{ID: 1, Type: ipc.RootNode, Nodes: []*ipc.Node{
+		{ID: 2, Type: ipc.ConNode, Rect: ipc.Rect{Width: 10, Height: 10}},
+		{ID: 3, Type: ipc.ConNode},
+	}}
+	after := &ipc.Node{ID: 1, Type: ipc.RootNode, Nodes: []*ipc.Node{
+		{ID: 2, Type: ipc.ConNode, Rect: ipc.Rect{Width: 20, Height: 10}},
+		{ID: 3, Type: ipc.ConNode, FullscreenMode: &full, Marks: []string{}},
+	}}
+
+	changes := node.Diff(before, after)
+	assert.Equal(t, []kindID{{node.Resized, 2}, {node.FullscreenChanged, 3}}, kinds(changes))
+	assert.Equal(t, "resized 2: {X:0 Y:0 Width:10 Height:10} -> {X:0 Y:0 Width:20 Height:10}", changes[0].String())
+
+	assert.Empty(t, node.Diff(after, after))
+	assert.Len(t, node.Diff(nil, after), 3)
+	assert.Equal(t, node.Removed, node.Diff(before, nil)[0].Kind)
+}
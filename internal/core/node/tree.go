@@ -0,0 +1,361 @@
+package node
+
+import "github.com/libanvl/swager/ipc"
+
+// Tree is an index over a snapshot of the sway tree. It knows the parent
+// and depth of each container and finds containers by id, pid, app_id and
+// mark without searching the tree.
+//
+// Methods taking a container only use its ID, so containers from events
+// can be passed as well. A Tree does not follow changes to the snapshot.
+type Tree struct {
+	root    *ipc.Node
+	entries map[int]entry
+	order   []*ipc.Node
+	pids    map[int][]*ipc.Node
+	appIDs  map[string][]*ipc.Node
+	marks   map[string]*ipc.Node
+	focused *ipc.Node
+}
+
+type entry struct {
+	n      *ipc.Node
+	parent *ipc.Node
+	depth  int
+}
+
+// NewTree indexes the tree below root.
+func NewTree(root *ipc.Node) *Tree {
+	t := &Tree{
+		root:    root,
+		entries: make(map[int]entry),
+		pids:    make(map[int][]*ipc.Node),
+		appIDs:  make(map[string][]*ipc.Node),
+		marks:   make(map[string]*ipc.Node),
+	}
+
+	t.index(root, nil, 0)
+	return t
+}
+
+func (t *Tree) index(n *ipc.Node, parent *ipc.Node, depth int) {
+	if n == nil {
+		return
+	}
+
+	t.entries[n.ID] = entry{n, parent, depth}
+	t.order = append(t.order, n)
+
+	if n.Focused && t.focused == nil {
+		t.focused = n
+	}
+
+	if n.Pid != nil {
+		t.pids[*n.Pid] = append(t.pids[*n.Pid], n)
+	}
+
+	if n.AppID != nil {
+		t.appIDs[*n.AppID] = append(t.appIDs[*n.AppID], n)
+	}
+
+	for _, m := range n.Marks {
+		t.marks[m] = n
+	}
+
+	for _, c := range n.Nodes {
+		t.index(c, n, depth+1)
+	}
+
+	for _, c := range n.FloatingNodes {
+		t.index(c, n, depth+1)
+	}
+}
+
+// Root returns the root of the tree.
+func (t *Tree) Root() *ipc.Node {
+	return t.root
+}
+
+// Len returns the number of containers in the tree.
+func (t *Tree) Len() int {
+	return len(t.order)
+}
+
+// Contains reports whether the container is in the tree.
+func (t *Tree) Contains(n *ipc.Node) bool {
+	_, ok := t.entries[n.ID]
+	return ok
+}
+
+// ByID returns the container with the given id, or nil.
+func (t *Tree) ByID(id int) *ipc.Node {
+	return t.entries[id].n
+}
+
+// ByPid returns the containers of the process with the given pid.
+func (t *Tree) ByPid(pid int) []*ipc.Node {
+	return t.pids[pid]
+}
+
+// ByAppID returns the containers with the given app_id.
+func (t *Tree) ByAppID(appID string) []*ipc.Node {
+	return t.appIDs[appID]
+}
+
+// ByMark returns the container with the given mark, or nil.
+// Sway allows a mark on one container at a time.
+func (t *Tree) ByMark(mark string) *ipc.Node {
+	return t.marks[mark]
+}
+
+// Focused returns the focused container, or nil.
+func (t *Tree) Focused() *ipc.Node {
+	return t.focused
+}
+
+// Parent returns the parent of the container, or nil for the root
+// and for containers not in the tree.
+func (t *Tree) Parent(n *ipc.Node) *ipc.Node {
+	return t.entries[n.ID].parent
+}
+
+// Depth returns how far the container is below the root,
+// or -1 if it is not in the tree.
+func (t *Tree) Depth(n *ipc.Node) int {
+	e, ok := t.entries[n.ID]
+	if !ok {
+		return -1
+	}
+
+	return e.depth
+}
+
+// Ancestors returns the ancestors of the container,
+// starting with its parent and ending with the root.
+func (t *Tree) Ancestors(n *ipc.Node) []*ipc.Node {
+	var ancestors []*ipc.Node
+	for p := t.Parent(n); p != nil; p = t.Parent(p) {
+		ancestors = append(ancestors, p)
+	}
+
+	return ancestors
+}
+
+// Path returns the containers from the root down to the container,
+// or nil if it is not in the tree.
+func (t *Tree) Path(n *ipc.Node) []*ipc.Node {
+	e, ok := t.entries[n.ID]
+	if !ok {
+		return nil
+	}
+
+	path := make([]*ipc.Node, e.depth+1)
+	for i, c := e.depth, e.n; c != nil; i, c = i-1, t.Parent(c) {
+		path[i] = c
+	}
+
+	return path
+}
+
+// Workspace returns the workspace holding the container, the container
+// itself if it is a workspace, or nil.
+func (t *Tree) Workspace(n *ipc.Node) *ipc.Node {
+	return t.enclosing(n, ipc.WorkspaceNode)
+}
+
+// Output returns the output holding the container, the container
+// itself if it is an output, or nil.
+func (t *Tree) Output(n *ipc.Node) *ipc.Node {
+	return t.enclosing(n, ipc.OutputNode)
+}
+
+func (t *Tree) enclosing(n *ipc.Node, typ ipc.NodeType) *ipc.Node {
+	for c := t.ByID(n.ID); c != nil; c = t.Parent(c) {
+		if c.Type == typ {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// Siblings returns the other children of the parent of the container,
+// tiling siblings of a tiling container and floating siblings of a
+// floating one.
+func (t *Tree) Siblings(n *ipc.Node) []*ipc.Node {
+	p := t.Parent(n)
+	if p == nil {
+		return nil
+	}
+
+	children := p.Nodes
+	if containsID(p.FloatingNodes, n.ID) {
+		children = p.FloatingNodes
+	}
+
+	var siblings []*ipc.Node
+	for _, c := range children {
+		if c.ID != n.ID {
+			siblings = append(siblings, c)
+		}
+	}
+
+	return siblings
+}
+
+// FocusOrder returns the tiling and floating children of the container,
+// most recently focused first, as listed by its Focus.
+func (t *Tree) FocusOrder(n *ipc.Node) []*ipc.Node {
+	n = t.ByID(n.ID)
+	if n == nil {
+		return nil
+	}
+
+	return focusOrder(n)
+}
+
+// FocusPath returns the containers from the root down to the leaf that
+// has or would get the focus, following the first entry of each Focus.
+func (t *Tree) FocusPath() []*ipc.Node {
+	var path []*ipc.Node
+	for n := t.root; n != nil; {
+		path = append(path, n)
+		if len(n.Focus) == 0 {
+			break
+		}
+
+		n = child(n, n.Focus[0])
+	}
+
+	return path
+}
+
+// MatchParentOf is the predicate MatchParentOf, without scanning
+// the children of every container. Like MatchParentOf, and unlike
+// Parent, it matches nothing for a floating container.
+func (t *Tree) MatchParentOf(child *ipc.Node) NodePredicate {
+	parent := t.Parent(child)
+	if parent != nil && containsID(parent.FloatingNodes, child.ID) {
+		parent = nil
+	}
+
+	return func(n *ipc.Node) bool {
+		return parent != nil && n.ID == parent.ID
+	}
+}
+
+// Iter returns an Iterator over the tree, depth first, visiting
+// tiling children before floating children.
+func (t *Tree) Iter() *Iterator {
+	return newIterator(t.root, false)
+}
+
+// FocusIter returns an Iterator over the tree, depth first, visiting
+// children in their focus order.
+func (t *Tree) FocusIter() *Iterator {
+	return newIterator(t.root, true)
+}
+
+// Iterator walks a tree one container at a time.
+//
+//	for it := tree.Iter(); it.Next(); {
+//		n := it.Node()
+//	}
+type Iterator struct {
+	stack  []*ipc.Node
+	n      *ipc.Node
+	pushed int
+	focus  bool
+}
+
+func newIterator(root *ipc.Node, focus bool) *Iterator {
+	it := &Iterator{focus: focus}
+	if root != nil {
+		it.stack = append(it.stack, root)
+	}
+
+	return it
+}
+
+// Next moves to the next container, returning false when there is none.
+func (it *Iterator) Next() bool {
+	if len(it.stack) == 0 {
+		it.n = nil
+		return false
+	}
+
+	it.n = it.stack[len(it.stack)-1]
+	it.stack = it.stack[:len(it.stack)-1]
+
+	// push the children last to first so they come off in order
+	n := len(it.stack)
+	if it.focus {
+		it.push(focusOrder(it.n))
+	} else {
+		it.push(it.n.FloatingNodes)
+		it.push(it.n.Nodes)
+	}
+
+	it.pushed = len(it.stack) - n
+	return true
+}
+
+func (it *Iterator) push(nodes []*ipc.Node) {
+	for i := len(nodes) - 1; i >= 0; i-- {
+		it.stack = append(it.stack, nodes[i])
+	}
+}
+
+// Node returns the current container.
+func (it *Iterator) Node() *ipc.Node {
+	return it.n
+}
+
+// SkipChildren makes Next pass over the children of the current container.
+func (it *Iterator) SkipChildren() {
+	it.stack = it.stack[:len(it.stack)-it.pushed]
+	it.pushed = 0
+}
+
+// focusOrder returns the children of n in the order of n.Focus,
+// followed by any children missing from it in tree order.
+func focusOrder(n *ipc.Node) []*ipc.Node {
+	children := make([]*ipc.Node, 0, len(n.Nodes)+len(n.FloatingNodes))
+	for _, id := range n.Focus {
+		if c := child(n, id); c != nil {
+			children = append(children, c)
+		}
+	}
+
+	for _, list := range [][]*ipc.Node{n.Nodes, n.FloatingNodes} {
+		for _, c := range list {
+			if !containsID(children, c.ID) {
+				children = append(children, c)
+			}
+		}
+	}
+
+	return children
+}
+
+func child(n *ipc.Node, id int) *ipc.Node {
+	for _, list := range [][]*ipc.Node{n.Nodes, n.FloatingNodes} {
+		for _, c := range list {
+			if c.ID == id {
+				return c
+			}
+		}
+	}
+
+	return nil
+}
+
+func containsID(nodes []*ipc.Node, id int) bool {
+	for _, n := range nodes {
+		if n.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,111 @@
+package node_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/libanvl/swager/internal/core/node"
+	"github.com/libanvl/swager/ipc"
+	"github.com/libanvl/swager/test/swaytest"
+	"github.com/stretchr/testify/assert"
+)
+
+func ids(nodes []*ipc.Node) []int {
+	var got []int
+	for _, n := range nodes {
+		got = append(got, n.ID)
+	}
+
+	return got
+}
+
+func TestTree(t *testing.T) {
+	st := swaytest.NewTree("eDP-1", "HDMI-A-1")
+	a := st.OpenWindow("a")
+	b := st.OpenWindow("b")
+	st.Apply("splitv")
+	c := st.OpenWindow("c")
+	st.Apply("[con_id=" + strconv.Itoa(a) + "] mark x")
+
+	tree := node.NewTree(st.Root())
+	root := tree.Root()
+	output := root.Nodes[0]
+	ws := output.Nodes[0]
+	split := tree.Parent(tree.ByID(c))
+
+	assert.Equal(t, node.Count(root, func(*ipc.Node) bool { return true }), tree.Len())
+	assert.Nil(t, tree.Parent(root))
+	assert.Equal(t, ws, tree.Parent(tree.ByID(a)))
+	assert.Equal(t, ws, tree.Parent(split))
+	assert.True(t, tree.MatchParentOf(tree.ByID(c))(split))
+	assert.False(t, tree.MatchParentOf(tree.ByID(c))(ws))
+
+	floating := &ipc.Node{ID: 100, Type: ipc.FloatingConNode}
+	fws := &ipc.Node{ID: 99, Type: ipc.WorkspaceNode, FloatingNodes: []*ipc.Node{floating}}
+	ftree := node.NewTree(fws)
+	assert.Equal(t, fws, ftree.Parent(floating))
+	assert.False(t, ftree.MatchParentOf(floating)(fws))
+	assert.False(t, node.MatchParentOf(floating)(fws))
+
+	assert.Equal(t, []int{split.ID, ws.ID, output.ID, root.ID}, ids(tree.Ancestors(tree.ByID(c))))
+	assert.Equal(t, []int{root.ID, output.ID, ws.ID, split.ID, c}, ids(tree.Path(tree.ByID(c))))
+	assert.Equal(t, 4, tree.Depth(tree.ByID(c)))
+	assert.Equal(t, 0, tree.Depth(root))
+	assert.Equal(t, -1, tree.Depth(&ipc.Node{ID: -5}))
+	assert.Nil(t, tree.Path(&ipc.Node{ID: -5}))
+
+	assert.Equal(t, ws, tree.Workspace(&ipc.Node{ID: c}))
+	assert.Equal(t, ws, tree.Workspace(ws))
+	assert.Equal(t, output, tree.Output(&ipc.Node{ID: c}))
+	assert.Nil(t, tree.Workspace(root))
+
+	assert.Equal(t, []int{split.ID}, ids(tree.Siblings(tree.ByID(a))))
+	assert.Equal(t, []int{b}, ids(tree.Siblings(tree.ByID(c))))
+	assert.Nil(t, tree.Siblings(root))
+
+	assert.Equal(t, []int{a}, ids(tree.ByAppID("a")))
+	assert.Empty(t, tree.ByAppID("z"))
+	assert.Equal(t, a, tree.ByMark("x").ID)
+	assert.Nil(t, tree.ByMark("y"))
+
+	assert.Equal(t, c, tree.Focused().ID)
+	assert.Equal(t, []int{root.ID, output.ID, ws.ID, split.ID, c}, ids(tree.FocusPath()))
+	assert.Equal(t, []int{split.ID, a}, ids(tree.FocusOrder(ws)))
+	assert.Equal(t, []int{c, b}, ids(tree.FocusOrder(split)))
+}
+
+func TestTreeIter(t *testing.T) {
+	pid := 42
+	root := &ipc.Node{ID: 1, Type: ipc.RootNode, Focus: []int{3, 2}, Nodes: []*ipc.Node{
+		{ID: 2, Type: ipc.ConNode, Nodes: []*ipc.Node{{ID: 4, Pid: &pid}}},
+		{ID: 3, Type: ipc.ConNode, Focus: []int{6, 5},
+			Nodes:         []*ipc.Node{{ID: 5}},
+			FloatingNodes: []*ipc.Node{{ID: 6, Type: ipc.FloatingConNode, Pid: &pid}, {ID: 7}}},
+	}}
+
+	tree := node.NewTree(root)
+	visit := func(it *node.Iterator, skip int) []int {
+		var got []int
+		for it.Next() {
+			got = append(got, it.Node().ID)
+			if it.Node().ID == skip {
+				it.SkipChildren()
+				it.SkipChildren()
+			}
+		}
+
+		assert.Nil(t, it.Node())
+		return got
+	}
+
+	assert.Equal(t, []int{1, 2, 4, 3, 5, 6, 7}, visit(tree.Iter(), 0))
+	assert.Equal(t, []int{1, 3, 6, 5, 7, 2, 4}, visit(tree.FocusIter(), 0))
+	assert.Equal(t, []int{1, 2, 3, 5, 6, 7}, visit(tree.Iter(), 2))
+	assert.Equal(t, []int{1, 3, 2, 4}, visit(tree.FocusIter(), 3))
+	assert.Empty(t, visit(node.NewTree(nil).Iter(), 0))
+
+	assert.Equal(t, []int{4, 6}, ids(tree.ByPid(42)))
+	assert.Equal(t, []int{7}, ids(tree.Siblings(tree.ByID(6))))
+	assert.Equal(t, []int{2}, ids(tree.Siblings(tree.ByID(3))))
+	assert.Equal(t, []int{1, 3, 6}, ids(tree.FocusPath()))
+}
@@ -88,6 +88,9 @@ func MatchNot(pred NodePredicate) NodePredicate {
 	}
 }
 
+// MatchParentOf matches the tiling parent of child, looking through the
+// children of each container. Tree.MatchParentOf matches the same
+// without the search.
 func MatchParentOf(child *ipc.Node) NodePredicate {
 	return func(n *ipc.Node) bool {
 		for _, nn := range n.Nodes {
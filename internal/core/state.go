@@ -0,0 +1,329 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/libanvl/swager/internal/core/node"
+	"github.com/libanvl/swager/ipc"
+)
+
+// State keeps a copy of the sway tree, loaded with GET_TREE once and
+// then kept current from window and workspace events. Events that only
+// change a single container, like focus, title and mark, are applied to
+// the copy. Events that move containers around, and any event the copy
+// cannot account for, make the next read load the tree again.
+//
+// The trees returned by a State are snapshots shared by every reader and
+// must not be modified. The State never modifies a tree once returned,
+// it replaces the changed containers and their ancestors instead.
+//
+// Handlers registered with WindowChanges are called after the State
+// applied the event, so they always read a tree that includes it.
+type State struct {
+	client     Client
+	mx         sync.Mutex
+	tree       *ipc.Node
+	index      *node.Tree
+	dirty      bool
+	handlersmx sync.Mutex
+	handlers   map[ipc.Cookie]func(ipc.WindowChange)
+	cookie     uint32
+}
+
+// NewState returns a State that loads the tree with client.
+// Call Attach to keep it current.
+func NewState(client Client) *State {
+	return &State{client: client, handlers: make(map[ipc.Cookie]func(ipc.WindowChange))}
+}
+
+// Attach registers the event handlers of the State on sub.
+func (st *State) Attach(sub Sub) error {
+	if _, err := sub.WindowChanges(st.WindowChanged); err != nil {
+		return err
+	}
+
+	if _, err := sub.WorkspaceChanges(st.WorkspaceChanged); err != nil {
+		return err
+	}
+
+	// events sent while disconnected are lost
+	if _, err := sub.Reconnects(func(ipc.Reconnected) { st.Invalidate() }); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Tree returns a snapshot of the whole tree.
+func (st *State) Tree() (*ipc.Node, error) {
+	st.mx.Lock()
+	defer st.mx.Unlock()
+
+	return st.current()
+}
+
+// FocusedWorkspace returns a snapshot of the workspace that holds
+// the focused container, or nil if nothing is focused.
+func (st *State) FocusedWorkspace() (*ipc.Node, error) {
+	st.mx.Lock()
+	defer st.mx.Unlock()
+
+	if _, err := st.current(); err != nil {
+		return nil, err
+	}
+
+	tree := st.indexed()
+	focused := tree.Focused()
+	if focused == nil {
+		return nil, nil
+	}
+
+	return tree.Workspace(focused), nil
+}
+
+// ContainerByID returns a snapshot of the container with the given id,
+// or nil if there is none.
+func (st *State) ContainerByID(id int) (*ipc.Node, error) {
+	st.mx.Lock()
+	defer st.mx.Unlock()
+
+	if _, err := st.current(); err != nil {
+		return nil, err
+	}
+
+	return st.indexed().ByID(id), nil
+}
+
+// Invalidate makes the next read load the tree again. Call it after
+// running commands that sway sends no event for, like split and layout.
+func (st *State) Invalidate() {
+	st.mx.Lock()
+	defer st.mx.Unlock()
+
+	st.dirty = true
+}
+
+// WindowChanges registers a handler called after the State applied
+// each window event.
+func (st *State) WindowChanges(h func(ipc.WindowChange)) (ipc.Cookie, error) {
+	cookie := ipc.Cookie(atomic.AddUint32(&st.cookie, 1))
+
+	st.handlersmx.Lock()
+	defer st.handlersmx.Unlock()
+	st.handlers[cookie] = h
+
+	return cookie, nil
+}
+
+// WindowChangesFiltered is WindowChanges, only calling h for events
+// with one of the change types, or any change when none are given,
+// and whose container matches pred, unless pred is nil.
+func (st *State) WindowChangesFiltered(h func(ipc.WindowChange), pred func(*ipc.Node) bool, changes ...ipc.WindowChangeType) (ipc.Cookie, error) {
+	return st.WindowChanges(ipc.FilterWindowChanges(h, pred, changes...))
+}
+
+// RemoveHandler removes a handler registered with WindowChanges.
+func (st *State) RemoveHandler(c ipc.Cookie) {
+	st.handlersmx.Lock()
+	defer st.handlersmx.Unlock()
+
+	delete(st.handlers, c)
+}
+
+// WindowChanged applies a window event, then calls the handlers
+// registered with WindowChanges.
+func (st *State) WindowChanged(evt ipc.WindowChange) {
+	st.mx.Lock()
+	switch evt.Change {
+	case ipc.FocusWindow:
+		st.focus(evt.Container.ID)
+	case ipc.TitleWindow, ipc.MarkWindow, ipc.UrgentWindow, ipc.FullscreenModeWindow:
+		st.replace(&evt.Container)
+	default:
+		st.dirty = true
+	}
+	st.mx.Unlock()
+
+	st.handlersmx.Lock()
+	handlers := make([]func(ipc.WindowChange), 0, len(st.handlers))
+	for _, h := range st.handlers {
+		handlers = append(handlers, h)
+	}
+	st.handlersmx.Unlock()
+
+	for _, h := range handlers {
+		h(evt)
+	}
+}
+
+// WorkspaceChanged applies a workspace event.
+func (st *State) WorkspaceChanged(evt ipc.WorkspaceChange) {
+	st.mx.Lock()
+	defer st.mx.Unlock()
+
+	if evt.Change == ipc.UrgentWorkspace && evt.Current != nil {
+		st.replace(evt.Current)
+		return
+	}
+
+	st.dirty = true
+}
+
+// current returns the tree, loading it when needed. st.mx must be held.
+func (st *State) current() (*ipc.Node, error) {
+	if st.tree != nil && !st.dirty {
+		return st.tree, nil
+	}
+
+	tree, err := st.client.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	st.set(tree)
+	st.dirty = false
+	return tree, nil
+}
+
+// indexed returns the index of the loaded tree, built once for each
+// tree. st.mx must be held.
+func (st *State) indexed() *node.Tree {
+	if st.index == nil {
+		st.index = node.NewTree(st.tree)
+	}
+
+	return st.index
+}
+
+// set replaces the tree, dropping the index of the old one.
+// st.mx must be held.
+func (st *State) set(tree *ipc.Node) {
+	st.tree = tree
+	st.index = nil
+}
+
+// replace swaps the container with the id of n for n.
+// st.mx must be held.
+func (st *State) replace(n *ipc.Node) {
+	if st.tree == nil || st.dirty {
+		return
+	}
+
+	tree := st.indexed()
+	path := tree.Path(n)
+	if len(path) == 0 {
+		// the copy missed the event that made the container
+		st.dirty = true
+		return
+	}
+
+	cp := *n
+	cp.Focused = path[len(path)-1].Focused
+	st.set(copyPath(path, &cp)[0])
+}
+
+// focus moves the focus to the container with the given id.
+// st.mx must be held.
+func (st *State) focus(id int) {
+	if st.tree == nil || st.dirty {
+		return
+	}
+
+	tree := st.indexed()
+	target := tree.ByID(id)
+	if target == nil {
+		st.dirty = true
+		return
+	}
+
+	root := st.tree
+	if from := tree.Focused(); from != nil {
+		old := *from
+		old.Focused = false
+		root = copyPath(tree.Path(from), &old)[0]
+	}
+
+	// the path to target in the new root, without indexing it again
+	path := follow(root, tree.Path(target))
+	focused := *path[len(path)-1]
+	focused.Focused = true
+	path = copyPath(path, &focused)
+
+	// put each container on the path first in the focus order of its parent
+	for i := 1; i < len(path); i++ {
+		path[i-1].Focus = raise(path[i-1].Focus, path[i].ID)
+	}
+
+	st.set(path[0])
+}
+
+// copyPath returns the path to a new root in which the last container
+// of path is replaced by n, copying its ancestors and sharing everything
+// else.
+func copyPath(path []*ipc.Node, n *ipc.Node) []*ipc.Node {
+	copied := make([]*ipc.Node, len(path))
+	copied[len(path)-1] = n
+	for i := len(path) - 2; i >= 0; i-- {
+		parent := *path[i]
+		parent.Nodes = replaceChild(parent.Nodes, n)
+		parent.FloatingNodes = replaceChild(parent.FloatingNodes, n)
+		n = &parent
+		copied[i] = n
+	}
+
+	return copied
+}
+
+// follow returns the containers with the ids of path, starting at root.
+func follow(root *ipc.Node, path []*ipc.Node) []*ipc.Node {
+	followed := make([]*ipc.Node, len(path))
+	followed[0] = root
+	for i := 1; i < len(path); i++ {
+		followed[i] = childByID(followed[i-1], path[i].ID)
+	}
+
+	return followed
+}
+
+func childByID(n *ipc.Node, id int) *ipc.Node {
+	for _, c := range n.Nodes {
+		if c.ID == id {
+			return c
+		}
+	}
+
+	for _, c := range n.FloatingNodes {
+		if c.ID == id {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func replaceChild(children []*ipc.Node, n *ipc.Node) []*ipc.Node {
+	for i, c := range children {
+		if c.ID == n.ID {
+			cp := make([]*ipc.Node, len(children))
+			copy(cp, children)
+			cp[i] = n
+			return cp
+		}
+	}
+
+	return children
+}
+
+// raise returns a copy of focus with id first.
+func raise(focus []int, id int) []int {
+	raised := make([]int, 0, len(focus)+1)
+	raised = append(raised, id)
+	for _, f := range focus {
+		if f != id {
+			raised = append(raised, f)
+		}
+	}
+
+	return raised
+}
@@ -0,0 +1,169 @@
+package core_test
+
+import (
+	"encoding/binary"
+	"strconv"
+	"testing"
+
+	"github.com/libanvl/swager/internal/core"
+	"github.com/libanvl/swager/ipc"
+	"github.com/libanvl/swager/test/swaytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStateHarness returns a State fed synchronously with the events
+// of a simulated tree, and a func counting its GET_TREE requests.
+func newStateHarness(t *testing.T) (*core.State, *swaytest.Tree, func() int) {
+	server := swaytest.NewServer(t)
+	tree := swaytest.NewTree("eDP-1")
+	server.Simulate(tree)
+
+	client, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+	require.Nil(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	state := core.NewState(client)
+	tree.OnEvent(func(ept ipc.EventPayloadType, args any) {
+		switch e := args.(type) {
+		case ipc.WindowChange:
+			state.WindowChanged(e)
+		case ipc.WorkspaceChange:
+			state.WorkspaceChanged(e)
+		}
+	})
+
+	loads := func() int {
+		n := 0
+		for _, m := range server.Received() {
+			if m.Type == ipc.GetTreeMessage {
+				n++
+			}
+		}
+		return n
+	}
+
+	return state, tree, loads
+}
+
+func TestStateAppliesEvents(t *testing.T) {
+	state, tree, loads := newStateHarness(t)
+	a := tree.OpenWindow("a")
+	b := tree.OpenWindow("b")
+
+	before, err := state.Tree()
+	require.Nil(t, err)
+	require.Equal(t, 1, loads())
+
+	tree.Apply("[con_id=" + strconv.Itoa(a) + "] focus")
+	tree.Apply("[con_id=" + strconv.Itoa(b) + "] mark picked")
+
+	focused, err := state.ContainerByID(a)
+	require.Nil(t, err)
+	require.NotNil(t, focused)
+	assert.True(t, focused.Focused)
+
+	ws, err := state.FocusedWorkspace()
+	require.Nil(t, err)
+	require.NotNil(t, ws)
+	assert.Equal(t, "1", ws.Name)
+	assert.Equal(t, a, ws.Focus[0])
+
+	marked, err := state.ContainerByID(b)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"picked"}, marked.Marks)
+	assert.False(t, marked.Focused)
+	assert.Equal(t, 1, loads(), "focus and mark must not load the tree")
+
+	// snapshots are never modified
+	old := findByID(before, a)
+	require.NotNil(t, old)
+	assert.False(t, old.Focused)
+	assert.Empty(t, findByID(before, b).Marks)
+
+	c := tree.OpenWindow("c")
+	opened, err := state.ContainerByID(c)
+	require.Nil(t, err)
+	assert.NotNil(t, opened)
+	assert.Equal(t, 2, loads())
+	assert.Equal(t, tree.Focused().ID, c)
+}
+
+func TestStateIndexFollowsTree(t *testing.T) {
+	state, tree, _ := newStateHarness(t)
+	a := tree.OpenWindow("a")
+	b := tree.OpenWindow("b")
+
+	for _, id := range []int{a, b, a} {
+		tree.Apply("[con_id=" + strconv.Itoa(id) + "] focus")
+
+		n, err := state.ContainerByID(id)
+		require.Nil(t, err)
+		assert.True(t, n.Focused)
+
+		ws, err := state.FocusedWorkspace()
+		require.Nil(t, err)
+		assert.Equal(t, id, ws.Focus[0])
+
+		again, err := state.ContainerByID(id)
+		require.Nil(t, err)
+		assert.Same(t, n, again, "lookups of one tree must share its index")
+	}
+
+	tree.Apply("[con_id=" + strconv.Itoa(b) + "] mark picked")
+	n, err := state.ContainerByID(b)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"picked"}, n.Marks)
+}
+
+func TestStateDrift(t *testing.T) {
+	state, tree, loads := newStateHarness(t)
+	a := tree.OpenWindow("a")
+	_, err := state.Tree()
+	require.Nil(t, err)
+
+	// an event for a container the copy does not know
+	state.WindowChanged(ipc.WindowChange{Change: ipc.TitleWindow, Container: ipc.Node{ID: 999}})
+	_, err = state.Tree()
+	require.Nil(t, err)
+	assert.Equal(t, 2, loads())
+
+	state.Invalidate()
+	n, err := state.ContainerByID(a)
+	require.Nil(t, err)
+	assert.NotNil(t, n)
+	assert.Equal(t, 3, loads())
+}
+
+func TestStateHandlersSeeTheEvent(t *testing.T) {
+	state, tree, _ := newStateHarness(t)
+	a := tree.OpenWindow("a")
+	tree.OpenWindow("b")
+
+	var seen []bool
+	_, err := state.WindowChangesFiltered(func(evt ipc.WindowChange) {
+		n, err := state.ContainerByID(evt.Container.ID)
+		require.Nil(t, err)
+		seen = append(seen, n.Focused)
+	}, nil, ipc.FocusWindow)
+	require.Nil(t, err)
+
+	tree.Apply("[con_id=" + strconv.Itoa(a) + "] focus")
+	assert.Equal(t, []bool{true}, seen)
+}
+
+func findByID(root *ipc.Node, id int) *ipc.Node {
+	if root.ID == id {
+		return root
+	}
+
+	for _, children := range [][]*ipc.Node{root.Nodes, root.FloatingNodes} {
+		for _, n := range children {
+			if found := findByID(n, id); found != nil {
+				return found
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package ipc
+
+type BarConfig struct {
+	ID                    string         `json:"id"`
+	Mode                  BarMode        `json:"mode"`
+	Position              BarPosition    `json:"position"`
+	HiddenState           BarHiddenState `json:"hidden_state"`
+	StatusCommand         *string        `json:"status_command"`
+	Font                  string         `json:"font"`
+	Gaps                  Gaps           `json:"gaps"`
+	BarHeight             int            `json:"bar_height"`
+	StatusPadding         int            `json:"status_padding"`
+	StatusEdgePadding     int            `json:"status_edge_padding"`
+	WorkspaceButtons      bool           `json:"workspace_buttons"`
+	WorkspaceMinWidth     int            `json:"workspace_min_width"`
+	StripWorkspaceNumbers bool           `json:"strip_workspace_numbers"`
+	StripWorkspaceName    bool           `json:"strip_workspace_name"`
+	BindingModeIndicator  bool           `json:"binding_mode_indicator"`
+	WrapScroll            bool           `json:"wrap_scroll"`
+	Verbose               bool           `json:"verbose"`
+	PangoMarkup           bool           `json:"pango_markup"`
+	Outputs               []string       `json:"outputs"`
+	TrayOutputs           []string       `json:"tray_outputs"`
+	TrayPadding           int            `json:"tray_padding"`
+	Colors                BarColors      `json:"colors"`
+}
+
+type BarMode string
+
+const (
+	DockBarMode      BarMode = "dock"
+	HideBarMode      BarMode = "hide"
+	InvisibleBarMode BarMode = "invisible"
+	OverlayBarMode   BarMode = "overlay"
+)
+
+type BarPosition string
+
+const (
+	TopBarPosition    BarPosition = "top"
+	BottomBarPosition BarPosition = "bottom"
+)
+
+type BarHiddenState string
+
+const (
+	HideBarHiddenState BarHiddenState = "hide"
+	ShowBarHiddenState BarHiddenState = "show"
+)
+
+type Gaps struct {
+	Top    int `json:"top"`
+	Right  int `json:"right"`
+	Bottom int `json:"bottom"`
+	Left   int `json:"left"`
+}
+
+// BarColors holds the bar colors as #RRGGBBAA strings.
+type BarColors struct {
+	Background              string `json:"background"`
+	Statusline              string `json:"statusline"`
+	Separator               string `json:"separator"`
+	FocusedBackground       string `json:"focused_background"`
+	FocusedStatusline       string `json:"focused_statusline"`
+	FocusedSeparator        string `json:"focused_separator"`
+	FocusedWorkspaceText    string `json:"focused_workspace_text"`
+	FocusedWorkspaceBG      string `json:"focused_workspace_bg"`
+	FocusedWorkspaceBorder  string `json:"focused_workspace_border"`
+	ActiveWorkspaceText     string `json:"active_workspace_text"`
+	ActiveWorkspaceBG       string `json:"active_workspace_bg"`
+	ActiveWorkspaceBorder   string `json:"active_workspace_border"`
+	InactiveWorkspaceText   string `json:"inactive_workspace_text"`
+	InactiveWorkspaceBG     string `json:"inactive_workspace_bg"`
+	InactiveWorkspaceBorder string `json:"inactive_workspace_border"`
+	UrgentWorkspaceText     string `json:"urgent_workspace_text"`
+	UrgentWorkspaceBG       string `json:"urgent_workspace_bg"`
+	UrgentWorkspaceBorder   string `json:"urgent_workspace_border"`
+	BindingModeText         string `json:"binding_mode_text"`
+	BindingModeBG           string `json:"binding_mode_bg"`
+	BindingModeBorder       string `json:"binding_mode_border"`
+}
@@ -1,24 +1,32 @@
 package ipc
 
 import (
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"io"
 	"net"
 	"os"
-	"sync"
+	"time"
 )
 
 // Client is a sway-ipc compatible rpc client.
 // Client is also an io.ReadWriteCloser.
 type Client struct {
 	io.ReadWriteCloser
-	yo    binary.ByteOrder
-	ipcmx sync.Mutex
+	yo      binary.ByteOrder
+	ipcmx   chan struct{}
+	timeout time.Duration
+	dial    func() (io.ReadWriteCloser, error)
+	broken  bool
+	backoff *Backoff
+	wrap    func(io.ReadWriteCloser) io.ReadWriteCloser
+	buf     []byte
+	index   treeIndex
 }
 
 func NewClient(conn io.ReadWriteCloser, yo binary.ByteOrder) *Client {
-	return &Client{conn, yo, sync.Mutex{}}
+	return &Client{ReadWriteCloser: conn, yo: yo, ipcmx: make(chan struct{}, 1)}
 }
 
 // Connect returns a Client connected to the UDS exported
@@ -30,127 +38,382 @@ func Connect() (*Client, error) {
 		return nil, os.ErrNotExist
 	}
 
-	return ConnectCustom(uds, binary.LittleEndian)
+	client, err := ConnectCustom(uds, binary.LittleEndian)
+	if err != nil {
+		return nil, err
+	}
+
+	// look SWAYSOCK up again on redial, in case it was updated
+	client.dial = func() (io.ReadWriteCloser, error) {
+		uds, present := os.LookupEnv("SWAYSOCK")
+		if !present {
+			return nil, os.ErrNotExist
+		}
+
+		return net.Dial("unix", uds)
+	}
+
+	return client, nil
 }
 
 // ConnectCustom returns a Client connected to the UDS
 // path specified by the uds parameter, with your choice of byte order.
 func ConnectCustom(uds string, yo binary.ByteOrder) (*Client, error) {
-	c, err := net.Dial("unix", uds)
+	dial := func() (io.ReadWriteCloser, error) {
+		return net.Dial("unix", uds)
+	}
+
+	c, err := dial()
 	if err != nil {
 		return nil, err
 	}
 
-	return NewClient(c, yo), nil
+	client := NewClient(c, yo)
+	client.dial = dial
+	return client, nil
+}
+
+// SetTimeout sets a deadline applied to every message sent by
+// the Client, in addition to any deadline on the context of a *Ctx call.
+// A zero duration, the default, disables the timeout.
+//
+// When a call is cancelled or times out part way through a message,
+// the connection is closed. Clients created with Connect or ConnectCustom
+// redial the socket on the next call, other Clients return ErrConnectionReset.
+func (c *Client) SetTimeout(d time.Duration) {
+	c.timeout = d
+}
+
+// WrapConn replaces the connection of the Client with the result of w,
+// and wraps every connection made when redialing the same way.
+// It is meant for observing the raw traffic, as ipc/record does.
+// The wrapper must forward SetDeadline when the connection has it.
+func (c *Client) WrapConn(w func(io.ReadWriteCloser) io.ReadWriteCloser) {
+	c.wrap = w
+	c.ReadWriteCloser = w(c.ReadWriteCloser)
 }
 
 // Command implements the sway-ipc RUN_COMMAND message.
 func (c *Client) Command(cmd string) ([]Command, error) {
-	return callgetarr[Command](c, RunCommandMessage, []byte(cmd))
+	return c.CommandCtx(context.Background(), cmd)
+}
+
+// CommandCtx is Command, honoring the cancellation and deadline of ctx.
+func (c *Client) CommandCtx(ctx context.Context, cmd string) ([]Command, error) {
+	return callgetarr[Command](ctx, c, RunCommandMessage, []byte(cmd))
+}
+
+// CommandChecked implements the sway-ipc RUN_COMMAND message.
+// It returns a *CommandError for the first sub-command that failed.
+func (c *Client) CommandChecked(cmd string) error {
+	return c.CommandCheckedCtx(context.Background(), cmd)
+}
+
+// CommandCheckedCtx is CommandChecked, honoring the cancellation and deadline of ctx.
+func (c *Client) CommandCheckedCtx(ctx context.Context, cmd string) error {
+	res, err := c.CommandCtx(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	return CheckCommand(cmd, res)
 }
 
 // CommandRaw implements the sway-ipc RUN_COMMAND message
 // and returns a json string.
 func (c *Client) CommandRaw(cmd string) (string, error) {
-	return c.ipccallraw(RunCommandMessage, []byte(cmd))
+	return c.ipccallraw(context.Background(), RunCommandMessage, []byte(cmd))
 }
 
 // Workspaces implements the sway-ipc GET_WORKSPACES message.
 func (c *Client) Workspaces() ([]Workspace, error) {
-	return callgetarr[Workspace](c, GetWorkspacesMessage, nil)
+	return c.WorkspacesCtx(context.Background())
+}
+
+// WorkspacesCtx is Workspaces, honoring the cancellation and deadline of ctx.
+func (c *Client) WorkspacesCtx(ctx context.Context) ([]Workspace, error) {
+	return callgetarr[Workspace](ctx, c, GetWorkspacesMessage, nil)
 }
 
 // Workspaces implements the sway-ipc GET_WORKSPACES message
 // and returns a json string.
 func (c *Client) WorkspacesRaw() (string, error) {
-	return c.ipccallraw(GetWorkspacesMessage, nil)
+	return c.ipccallraw(context.Background(), GetWorkspacesMessage, nil)
 }
 
 // Subscribe implements the sway-ipc SUBSCRIBE message.
 func (c *Client) Subscribe(evts ...EventPayloadType) (*Result, error) {
+	return c.SubscribeCtx(context.Background(), evts...)
+}
+
+// SubscribeCtx is Subscribe, honoring the cancellation and deadline of ctx.
+func (c *Client) SubscribeCtx(ctx context.Context, evts ...EventPayloadType) (*Result, error) {
 	pbytes, err := json.Marshal(eventNames(evts))
 	if err != nil {
 		// panic here because this shouldn't be possible
 		panic(err)
 	}
 
-	return callgetptr[Result](c, SubscribeMessage, pbytes)
+	return callgetptr[Result](ctx, c, SubscribeMessage, pbytes)
 }
 
 // Outputs implements the sway-ipc GET_OUTPUTS message.
 func (c *Client) Outputs() ([]Output, error) {
-	return callgetarr[Output](c, GetOutputsMessage, nil)
+	return c.OutputsCtx(context.Background())
+}
+
+// OutputsCtx is Outputs, honoring the cancellation and deadline of ctx.
+func (c *Client) OutputsCtx(ctx context.Context) ([]Output, error) {
+	return callgetarr[Output](ctx, c, GetOutputsMessage, nil)
 }
 
 // Outputs implements the sway-ipc GET_OUTPUTS message
 // and returns a json string.
 func (c *Client) OutputsRaw() (string, error) {
-	return c.ipccallraw(GetOutputsMessage, nil)
+	return c.ipccallraw(context.Background(), GetOutputsMessage, nil)
 }
 
 // Tree implements the sway-ipc GET_TREE message.
 // Returns a *Node representing the root of the tree.
 func (c *Client) Tree() (*Node, error) {
-	return callgetptr[Node](c, GetTreeMessage, nil)
+	return c.TreeCtx(context.Background())
+}
+
+// TreeCtx is Tree, honoring the cancellation and deadline of ctx.
+func (c *Client) TreeCtx(ctx context.Context) (*Node, error) {
+	return callgetptr[Node](ctx, c, GetTreeMessage, nil)
+}
+
+// TreeWhere implements the sway-ipc GET_TREE message, returning only
+// the containers matching pred, in tree order, with their descendants.
+// pred sees the ID, Name, Type, Focused, Focus and Marks of a container,
+// without its children, and must not keep it. The descendants of a match
+// are not passed to pred. Containers that do not match are never fully
+// decoded, which saves most of the work of Tree when only a part of the
+// tree is needed.
+func (c *Client) TreeWhere(pred func(*Node) bool) ([]*Node, error) {
+	return c.TreeWhereCtx(context.Background(), pred)
+}
+
+// TreeWhereCtx is TreeWhere, honoring the cancellation and deadline of ctx.
+func (c *Client) TreeWhereCtx(ctx context.Context, pred func(*Node) bool) ([]*Node, error) {
+	var matches []*Node
+	err := c.ipcdecode(ctx, GetTreeMessage, nil, true, func(res []byte) error {
+		var err error
+		matches, err = c.index.where(res, pred)
+		return err
+	})
+
+	return matches, err
+}
+
+// FocusedWorkspace implements the sway-ipc GET_TREE message, returning
+// only the focused workspace, or nil if there is none. It follows the
+// focus order down from the root and decodes no other workspace.
+func (c *Client) FocusedWorkspace() (*Node, error) {
+	return c.FocusedWorkspaceCtx(context.Background())
+}
+
+// FocusedWorkspaceCtx is FocusedWorkspace, honoring the cancellation
+// and deadline of ctx.
+func (c *Client) FocusedWorkspaceCtx(ctx context.Context) (*Node, error) {
+	var ws *Node
+	err := c.ipcdecode(ctx, GetTreeMessage, nil, true, func(res []byte) error {
+		var err error
+		ws, err = c.index.focusedWorkspace(res)
+		return err
+	})
+
+	return ws, err
 }
 
 // Tree implements the sway-ipc GET_TREE message
 // and returns a json string.
 func (c *Client) TreeRaw() (string, error) {
-	return c.ipccallraw(GetTreeMessage, nil)
+	return c.ipccallraw(context.Background(), GetTreeMessage, nil)
 }
 
 // Marks implements the sway-ipc GET_MARKS message.
 func (c *Client) Marks() ([]string, error) {
-	return callgetarr[string](c, GetMarksMessage, nil)
+	return c.MarksCtx(context.Background())
+}
+
+// MarksCtx is Marks, honoring the cancellation and deadline of ctx.
+func (c *Client) MarksCtx(ctx context.Context) ([]string, error) {
+	return callgetarr[string](ctx, c, GetMarksMessage, nil)
 }
 
 // Marks implements the sway-ipc GET_MARKS message
 // and returns a json string.
 func (c *Client) MarksRaw() (string, error) {
-	return c.ipccallraw(GetMarksMessage, nil)
+	return c.ipccallraw(context.Background(), GetMarksMessage, nil)
+}
+
+// BarIDs implements the sway-ipc GET_BAR_CONFIG message
+// without a payload. Returns the ids of all configured bars.
+func (c *Client) BarIDs() ([]string, error) {
+	return c.BarIDsCtx(context.Background())
+}
+
+// BarIDsCtx is BarIDs, honoring the cancellation and deadline of ctx.
+func (c *Client) BarIDsCtx(ctx context.Context) ([]string, error) {
+	return callgetarr[string](ctx, c, GetBarConfigMessage, nil)
+}
+
+// BarIDs implements the sway-ipc GET_BAR_CONFIG message
+// without a payload and returns a json string.
+func (c *Client) BarIDsRaw() (string, error) {
+	return c.ipccallraw(context.Background(), GetBarConfigMessage, nil)
+}
+
+// BarConfig implements the sway-ipc GET_BAR_CONFIG message
+// for the bar with the given id.
+func (c *Client) BarConfig(id string) (*BarConfig, error) {
+	return c.BarConfigCtx(context.Background(), id)
+}
+
+// BarConfigCtx is BarConfig, honoring the cancellation and deadline of ctx.
+func (c *Client) BarConfigCtx(ctx context.Context, id string) (*BarConfig, error) {
+	return callgetptr[BarConfig](ctx, c, GetBarConfigMessage, []byte(id))
+}
+
+// BarConfig implements the sway-ipc GET_BAR_CONFIG message
+// for the bar with the given id and returns a json string.
+func (c *Client) BarConfigRaw(id string) (string, error) {
+	return c.ipccallraw(context.Background(), GetBarConfigMessage, []byte(id))
 }
 
 // Version implements the sway-ipc GET_VERSION message.
 func (c *Client) Version() (*Version, error) {
-	return callgetptr[Version](c, GetVersionMessage, nil)
+	return c.VersionCtx(context.Background())
+}
+
+// VersionCtx is Version, honoring the cancellation and deadline of ctx.
+func (c *Client) VersionCtx(ctx context.Context) (*Version, error) {
+	return callgetptr[Version](ctx, c, GetVersionMessage, nil)
 }
 
 // Version implements the sway-ipc GET_VERSION message
 // and returns a json string.
 func (c *Client) VersionRaw() (string, error) {
-	return c.ipccallraw(GetVersionMessage, nil)
+	return c.ipccallraw(context.Background(), GetVersionMessage, nil)
 }
 
 // BindingModes implements the sway-ipc GET_BINDING_MODES message.
 func (c *Client) BindingModes() ([]string, error) {
-	return callgetarr[string](c, GetBindingModesMessage, nil)
+	return c.BindingModesCtx(context.Background())
+}
+
+// BindingModesCtx is BindingModes, honoring the cancellation and deadline of ctx.
+func (c *Client) BindingModesCtx(ctx context.Context) ([]string, error) {
+	return callgetarr[string](ctx, c, GetBindingModesMessage, nil)
 }
 
 // BindingModes implements the sway-ipc GET_BINDING_MODES message
 // and returns a json string.
 func (c *Client) BindingModesRaw() (string, error) {
-	return c.ipccallraw(GetBindingModesMessage, nil)
+	return c.ipccallraw(context.Background(), GetBindingModesMessage, nil)
+}
+
+// Config implements the sway-ipc GET_CONFIG message.
+// Returns the contents of the last loaded config file.
+func (c *Client) Config() (string, error) {
+	return c.ConfigCtx(context.Background())
+}
+
+// ConfigCtx is Config, honoring the cancellation and deadline of ctx.
+func (c *Client) ConfigCtx(ctx context.Context) (string, error) {
+	cfg, err := callgetptr[Config](ctx, c, GetConfigMessage, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return cfg.Config, nil
+}
+
+// Config implements the sway-ipc GET_CONFIG message
+// and returns a json string.
+func (c *Client) ConfigRaw() (string, error) {
+	return c.ipccallraw(context.Background(), GetConfigMessage, nil)
 }
 
 // Tick implements the sway-ipc SEND_TICK message.
 func (c *Client) Tick(payload string) (*Result, error) {
-	return callgetptr[Result](c, SendTickMessage, []byte(payload))
+	return c.TickCtx(context.Background(), payload)
+}
+
+// TickCtx is Tick, honoring the cancellation and deadline of ctx.
+func (c *Client) TickCtx(ctx context.Context, payload string) (*Result, error) {
+	return callgetptr[Result](ctx, c, SendTickMessage, []byte(payload))
 }
 
 // Tick implements the sway-ipc SEND_TICK message
 // and returns a json string.
 func (c *Client) TickRaw(payload string) (string, error) {
-	return c.ipccallraw(SendTickMessage, []byte(payload))
+	return c.ipccallraw(context.Background(), SendTickMessage, []byte(payload))
+}
+
+// Sync implements the sway-ipc SYNC message. Sway only answers it for
+// compatibility with i3, so Success is always false.
+func (c *Client) Sync() (*Result, error) {
+	return c.SyncCtx(context.Background())
+}
+
+// SyncCtx is Sync, honoring the cancellation and deadline of ctx.
+func (c *Client) SyncCtx(ctx context.Context) (*Result, error) {
+	return callgetptr[Result](ctx, c, SyncMessage, nil)
+}
+
+// Sync implements the sway-ipc SYNC message
+// and returns a json string.
+func (c *Client) SyncRaw() (string, error) {
+	return c.ipccallraw(context.Background(), SyncMessage, nil)
 }
 
 // BindingState implements the sway-ipc GET_BINDING_STATE message.
 func (c *Client) BindingState() (*BindingState, error) {
-	return callgetptr[BindingState](c, GetBindingStateMessage, nil)
+	return c.BindingStateCtx(context.Background())
+}
+
+// BindingStateCtx is BindingState, honoring the cancellation and deadline of ctx.
+func (c *Client) BindingStateCtx(ctx context.Context) (*BindingState, error) {
+	return callgetptr[BindingState](ctx, c, GetBindingStateMessage, nil)
 }
 
 // BindingState implements the sway-ipc GET_BINDING_STATE message
 // and returns a json string.
 func (c *Client) BindingStateRaw() (string, error) {
-	return c.ipccallraw(GetBindingStateMessage, nil)
+	return c.ipccallraw(context.Background(), GetBindingStateMessage, nil)
+}
+
+// Inputs implements the sway-ipc GET_INPUTS message.
+func (c *Client) Inputs() ([]Input, error) {
+	return c.InputsCtx(context.Background())
+}
+
+// InputsCtx is Inputs, honoring the cancellation and deadline of ctx.
+func (c *Client) InputsCtx(ctx context.Context) ([]Input, error) {
+	return callgetarr[Input](ctx, c, GetInputsMessage, nil)
+}
+
+// Inputs implements the sway-ipc GET_INPUTS message
+// and returns a json string.
+func (c *Client) InputsRaw() (string, error) {
+	return c.ipccallraw(context.Background(), GetInputsMessage, nil)
+}
+
+// Seats implements the sway-ipc GET_SEATS message.
+func (c *Client) Seats() ([]Seat, error) {
+	return c.SeatsCtx(context.Background())
+}
+
+// SeatsCtx is Seats, honoring the cancellation and deadline of ctx.
+func (c *Client) SeatsCtx(ctx context.Context) ([]Seat, error) {
+	return callgetarr[Seat](ctx, c, GetSeatsMessage, nil)
+}
+
+// Seats implements the sway-ipc GET_SEATS message
+// and returns a json string.
+func (c *Client) SeatsRaw() (string, error) {
+	return c.ipccallraw(context.Background(), GetSeatsMessage, nil)
 }
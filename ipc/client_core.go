@@ -1,30 +1,168 @@
 package ipc
 
 import (
+	"context"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"io"
+	"os"
+	"strings"
+	"time"
 )
 
-func (c *Client) ipccall(pt PayloadType, payload []byte) ([]byte, error) {
-	c.ipcmx.Lock()
-	defer c.ipcmx.Unlock()
+// deadliner is implemented by connections that support
+// I/O deadlines, such as net.Conn.
+type deadliner interface {
+	SetDeadline(t time.Time) error
+}
 
-	if err := c.write(pt, payload); err != nil {
-		return nil, err
+func (c *Client) ipccall(ctx context.Context, pt PayloadType, payload []byte) ([]byte, error) {
+	var res []byte
+	err := c.ipcdecode(ctx, pt, payload, false, func(buf []byte) error {
+		res = buf
+		return nil
+	})
+
+	return res, err
+}
+
+// ipcdecode sends a message and hands the reply to decode before the next
+// call can start. With reuse, the reply is read into a buffer kept by the
+// Client for the next call, so decode must not keep it. Calls wait for
+// decode, so only decoders that skip most of the reply should use it.
+func (c *Client) ipcdecode(ctx context.Context, pt PayloadType, payload []byte, reuse bool, decode func([]byte) error) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case c.ipcmx <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-c.ipcmx }()
+
+	if err := c.ensureConn(ctx); err != nil {
+		return err
+	}
+
+	stop := c.interruptOn(ctx)
+
+	err := c.write(pt, payload)
+	var res []byte
+	if err == nil {
+		if reuse {
+			res, err = c.readReused()
+		} else {
+			res, err = c.read()
+		}
+	}
+
+	if closed := stop(); closed || (err != nil && interrupted(ctx, err)) {
+		// The call was cut off somewhere inside a message,
+		// so the framing of the connection can no longer be trusted.
+		c.reset()
+		if err == nil {
+			return decode(res)
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		return context.DeadlineExceeded
 	}
 
-	return c.read()
+	if err != nil && c.backoff != nil && c.dial != nil {
+		// reconnecting mode: redial on the next call
+		c.reset()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return decode(res)
 }
 
-func (c *Client) ipccallraw(pt PayloadType, payload []byte) (string, error) {
-	res, err := c.ipccall(pt, payload)
+func (c *Client) ipccallraw(ctx context.Context, pt PayloadType, payload []byte) (string, error) {
+	res, err := c.ipccall(ctx, pt, payload)
 	if err != nil {
 		return "", err
 	}
 	return string(res), nil
 }
 
+// interruptOn applies the deadline of ctx to the connection and
+// unblocks any pending I/O when ctx is done. The returned func must be
+// called once the call completes. It reports whether the connection was
+// closed to unblock the call.
+func (c *Client) interruptOn(ctx context.Context) func() bool {
+	if ctx.Done() == nil {
+		return func() bool { return false }
+	}
+
+	conn := c.ReadWriteCloser
+	d, canDeadline := conn.(deadliner)
+	if deadline, ok := ctx.Deadline(); ok && canDeadline {
+		d.SetDeadline(deadline)
+	}
+
+	done := make(chan struct{})
+	closed := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			if canDeadline {
+				d.SetDeadline(time.Unix(1, 0))
+				closed <- false
+			} else {
+				conn.Close()
+				closed <- true
+			}
+		case <-done:
+			closed <- false
+		}
+	}()
+
+	return func() bool {
+		close(done)
+		wasClosed := <-closed
+		if canDeadline {
+			d.SetDeadline(time.Time{})
+		}
+		return wasClosed
+	}
+}
+
+func interrupted(ctx context.Context, err error) bool {
+	return ctx.Err() != nil || errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// reset closes the connection after an interrupted call.
+// The next call redials if the Client knows how to, otherwise
+// it fails with ErrConnectionReset.
+func (c *Client) reset() {
+	c.ReadWriteCloser.Close()
+	c.broken = true
+}
+
+func (c *Client) ensureConn(ctx context.Context) error {
+	if !c.broken {
+		return nil
+	}
+
+	_, err := c.redial(ctx)
+	return err
+}
+
 func (c *Client) write(pt PayloadType, payload []byte) error {
 	h := NewHeader(pt, len(payload))
 	if err := binary.Write(c, c.yo, h); err != nil {
@@ -42,22 +180,161 @@ func (c *Client) write(pt PayloadType, payload []byte) error {
 }
 
 func (c *Client) read() ([]byte, error) {
+	_, buf, err := c.readMessage()
+	return buf, err
+}
+
+// readMessage reads the next message and its type.
+func (c *Client) readMessage() (PayloadType, []byte, error) {
 	var h Header
 	if err := binary.Read(c, c.yo, &h); err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 
 	buf := make([]byte, int(h.PayloadLength))
 	_, err := io.ReadFull(c, buf)
 	if err != nil {
+		return 0, nil, err
+	}
+
+	return h.PayloadType, buf, nil
+}
+
+// maxReusedBuffer is the size of the largest reply buffer
+// a Client keeps for the next call.
+const maxReusedBuffer = 4 << 20
+
+// readReused is read, into the buffer of the Client. The result is
+// overwritten by the next call.
+func (c *Client) readReused() ([]byte, error) {
+	var h Header
+	if err := binary.Read(c, c.yo, &h); err != nil {
+		return nil, err
+	}
+
+	n := int(h.PayloadLength)
+	buf := c.buf
+	if cap(buf) < n {
+		buf = make([]byte, n)
+		if n <= maxReusedBuffer {
+			c.buf = buf
+		}
+	}
+
+	buf = buf[:n]
+	if _, err := io.ReadFull(c, buf); err != nil {
 		return nil, err
 	}
 
 	return buf, nil
 }
 
-func callgetptr[T interface{}](c *Client, pt PayloadType, payload []byte) (*T, error) {
-	res, err := c.ipccall(pt, payload)
+// SplitCommand splits a RUN_COMMAND payload into its sub-commands
+// the same way sway does: on ';' and ',' outside of quotes and criteria.
+// Sway replies with one result per sub-command, in this order.
+func SplitCommand(cmd string) []string {
+	var cmds []string
+	for _, group := range SplitCommandGroups(cmd) {
+		cmds = append(cmds, group...)
+	}
+
+	return cmds
+}
+
+// SplitCommandGroups is SplitCommand, keeping the sub-commands separated
+// by ',' together. Each group starts after a ';'. Criteria in front of a
+// sub-command apply to the rest of its group.
+func SplitCommandGroups(cmd string) [][]string {
+	var groups [][]string
+	var group []string
+	start := 0
+
+	add := func(end int, endGroup bool) {
+		if c := strings.TrimSpace(cmd[start:end]); c != "" {
+			group = append(group, c)
+		}
+		start = end + 1
+
+		if endGroup && len(group) > 0 {
+			groups = append(groups, group)
+			group = nil
+		}
+	}
+
+	scanCommand(cmd, func(i int, r rune) bool {
+		switch r {
+		case ';':
+			add(i, true)
+		case ',':
+			add(i, false)
+		}
+		return true
+	})
+
+	add(len(cmd), true)
+	return groups
+}
+
+// SplitCriteria splits a sub-command returned by SplitCommand into its
+// criteria, without the brackets, and the rest. The criteria are empty
+// when the sub-command has none. ok is false when the criteria are
+// not closed.
+func SplitCriteria(cmd string) (criteria string, rest string, ok bool) {
+	cmd = strings.TrimSpace(cmd)
+	if !strings.HasPrefix(cmd, "[") {
+		return "", cmd, true
+	}
+
+	end := -1
+	scanCommand(cmd, func(i int, r rune) bool {
+		if r == ']' {
+			end = i
+		}
+		return end < 0
+	})
+
+	if end < 0 {
+		return "", cmd, false
+	}
+
+	return cmd[1:end], strings.TrimSpace(cmd[end+1:]), true
+}
+
+// scanCommand calls sep with the offset of each ';' and ',' outside of
+// quotes and criteria, and of each ']' closing criteria, until sep
+// returns false. A backslash escapes the next character.
+func scanCommand(cmd string, sep func(i int, r rune) bool) {
+	var quote rune
+	escaped, criteria := false, false
+	for i, r := range cmd {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '[':
+			criteria = true
+		case r == ']' && criteria:
+			criteria = false
+			if !sep(i, r) {
+				return
+			}
+		case !criteria && (r == ';' || r == ','):
+			if !sep(i, r) {
+				return
+			}
+		}
+	}
+}
+
+func callgetptr[T interface{}](ctx context.Context, c *Client, pt PayloadType, payload []byte) (*T, error) {
+	res, err := c.ipccall(ctx, pt, payload)
 	if err != nil {
 		return nil, err
 	}
@@ -70,8 +347,8 @@ func callgetptr[T interface{}](c *Client, pt PayloadType, payload []byte) (*T, e
 	return t, nil
 }
 
-func callgetarr[T interface{}](c *Client, pt PayloadType, payload []byte) ([]T, error) {
-	res, err := c.ipccall(pt, payload)
+func callgetarr[T interface{}](ctx context.Context, c *Client, pt PayloadType, payload []byte) ([]T, error) {
+	res, err := c.ipccall(ctx, pt, payload)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,54 @@
+package ipc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrConnectionReset is returned by a Client that closed its connection
+// after an interrupted call and has no way to redial it.
+var ErrConnectionReset = errors.New("ipc: connection reset after interrupted call")
+
+// CommandError describes the sub-command of a RUN_COMMAND message
+// that sway reported as failed.
+type CommandError struct {
+	// Index is the position of the sub-command in the message,
+	// counting commands separated by ';' and ','.
+	Index int
+	// Command is the text of the sub-command, without separators.
+	Command string
+	// ParseError is set when sway could not parse the sub-command.
+	ParseError bool
+	// Message is the error reported by sway.
+	Message string
+}
+
+func (e *CommandError) Error() string {
+	kind := "failed"
+	if e.ParseError {
+		kind = "could not be parsed"
+	}
+
+	return fmt.Sprintf("command %d '%s' %s: %s", e.Index, e.Command, kind, e.Message)
+}
+
+// CheckCommand maps the results of a RUN_COMMAND message back to
+// the sub-commands of cmd. It returns a *CommandError for the first
+// result that was not successful, or nil.
+func CheckCommand(cmd string, results []Command) error {
+	cmds := SplitCommand(cmd)
+	for i, r := range results {
+		if r.Success {
+			continue
+		}
+
+		e := &CommandError{Index: i, ParseError: r.ParseError, Message: r.Error}
+		if i < len(cmds) {
+			e.Command = cmds[i]
+		}
+
+		return e
+	}
+
+	return nil
+}
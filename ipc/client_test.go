@@ -2,11 +2,14 @@ package ipc_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"encoding/json"
+	"io"
 	"net"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/libanvl/swager/ipc"
 	"github.com/libanvl/swager/test"
@@ -45,6 +48,8 @@ func TestPayloadWrites(t *testing.T) {
 		"CommandRaw":            {func(c *ipc.Client, s string) { c.CommandRaw(s) }, "[app_id=testpayload] testpayload2"},
 		"Tick":                  {func(c *ipc.Client, s string) { c.Tick(s) }, "testpayload"},
 		"TickRaw":               {func(c *ipc.Client, s string) { c.TickRaw(s) }, "testpayload"},
+		"BarConfig":             {func(c *ipc.Client, s string) { c.BarConfig(s) }, "bar-0"},
+		"BarConfigRaw":          {func(c *ipc.Client, s string) { c.BarConfigRaw(s) }, "bar-0"},
 	}
 
 	for name, tc := range tests {
@@ -106,12 +111,22 @@ func TestNoPayloadWrites(t *testing.T) {
 		"TreeRaw":         {func(c *ipc.Client) { c.TreeRaw() }},
 		"Marks":           {func(c *ipc.Client) { c.Marks() }},
 		"MarksRaw":        {func(c *ipc.Client) { c.MarksRaw() }},
+		"BarIDs":          {func(c *ipc.Client) { c.BarIDs() }},
+		"BarIDsRaw":       {func(c *ipc.Client) { c.BarIDsRaw() }},
 		"Version":         {func(c *ipc.Client) { c.Version() }},
 		"VersionRaw":      {func(c *ipc.Client) { c.VersionRaw() }},
 		"BindingModes":    {func(c *ipc.Client) { c.BindingModes() }},
 		"BindingModesRaw": {func(c *ipc.Client) { c.BindingModesRaw() }},
 		"BindingState":    {func(c *ipc.Client) { c.BindingState() }},
 		"BindingStateRaw": {func(c *ipc.Client) { c.BindingStateRaw() }},
+		"Inputs":          {func(c *ipc.Client) { c.Inputs() }},
+		"InputsRaw":       {func(c *ipc.Client) { c.InputsRaw() }},
+		"Seats":           {func(c *ipc.Client) { c.Seats() }},
+		"SeatsRaw":        {func(c *ipc.Client) { c.SeatsRaw() }},
+		"Config":          {func(c *ipc.Client) { c.Config() }},
+		"ConfigRaw":       {func(c *ipc.Client) { c.ConfigRaw() }},
+		"Sync":            {func(c *ipc.Client) { c.Sync() }},
+		"SyncRaw":         {func(c *ipc.Client) { c.SyncRaw() }},
 	}
 
 	for name, tc := range tests {
@@ -136,6 +151,7 @@ func TestNoPayloadWrites(t *testing.T) {
 
 func TestNoPayloadReads(t *testing.T) {
 	type action func(*ipc.Client) (any, error)
+	layout := "English (US)"
 	tests := map[string]struct {
 		payload  ipc.PayloadType
 		action   action
@@ -186,6 +202,46 @@ func TestNoPayloadReads(t *testing.T) {
 				},
 			},
 		},
+		"BarIDs": {
+			ipc.GetBarConfigMessage,
+			func(c *ipc.Client) (any, error) { return c.BarIDs() },
+			[]string{"bar-0", "bar-1"},
+		},
+		"Inputs": {
+			ipc.GetInputsMessage,
+			func(c *ipc.Client) (any, error) { return c.Inputs() },
+			[]ipc.Input{
+				{
+					Identifier:          "1:1:TestKeyboard",
+					Name:                "TestKeyboard",
+					Vendor:              1,
+					Product:             1,
+					Type:                ipc.KeyboardInputDevice,
+					XkbActiveLayoutName: &layout,
+					XkbLayoutNames:      []string{"English (US)", "German"},
+					Libinput:            &ipc.Libinput{SendEvents: "enabled"},
+				},
+			},
+		},
+		"Seats": {
+			ipc.GetSeatsMessage,
+			func(c *ipc.Client) (any, error) { return c.Seats() },
+			[]ipc.Seat{
+				{
+					Name:         "seat0",
+					Capabilities: 3,
+					Focus:        7,
+					Devices: []ipc.Input{
+						{Identifier: "2:2:TestPointer", Type: ipc.PointerInputDevice},
+					},
+				},
+			},
+		},
+		"Sync": {
+			ipc.SyncMessage,
+			func(c *ipc.Client) (any, error) { return c.Sync() },
+			&ipc.Result{Success: true},
+		},
 		"Version": {
 			ipc.GetVersionMessage,
 			func(c *ipc.Client) (any, error) { return c.Version() },
@@ -219,6 +275,65 @@ func TestNoPayloadReads(t *testing.T) {
 	}
 }
 
+func TestConfig(t *testing.T) {
+	conn := test.NewMockConnection(t)
+	client := ipc.NewClient(conn, binary.LittleEndian)
+	expected := "set $mod Mod4\nbindsym $mod+Return exec foot\n"
+	reply, err := json.Marshal(ipc.Config{Config: expected})
+	require.Nil(t, err)
+	conn.PushPayloadForRead(uint32(ipc.GetConfigMessage), reply, binary.LittleEndian)
+
+	actual, err := client.Config()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestPayloadReads(t *testing.T) {
+	type action func(*ipc.Client, string) (any, error)
+	status := "i3status"
+	tests := map[string]struct {
+		payload  ipc.PayloadType
+		action   action
+		arg      string
+		expected any
+	}{
+		"BarConfig": {
+			ipc.GetBarConfigMessage,
+			func(c *ipc.Client, s string) (any, error) { return c.BarConfig(s) },
+			"bar-0",
+			&ipc.BarConfig{
+				ID:            "bar-0",
+				Mode:          ipc.DockBarMode,
+				Position:      ipc.TopBarPosition,
+				HiddenState:   ipc.HideBarHiddenState,
+				StatusCommand: &status,
+				Gaps:          ipc.Gaps{Top: 1, Right: 2, Bottom: 3, Left: 4},
+				TrayOutputs:   []string{"TestOutput"},
+				Colors: ipc.BarColors{
+					Background:         "#000000ff",
+					FocusedWorkspaceBG: "#4c7899ff",
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		for _, yo := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			t.Run(name+"_"+yo.String(), func(t *testing.T) {
+				conn := test.NewMockConnection(t)
+				client := ipc.NewClient(conn, yo)
+				expected_json, err := json.Marshal(tc.expected)
+				require.Nil(t, err)
+				conn.PushPayloadForRead(uint32(tc.payload), expected_json, yo)
+				actual, err := tc.action(client, tc.arg)
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expected, actual)
+				assert.Equal(t, tc.arg, string(conn.WriteAt(1)))
+			})
+		}
+	}
+}
+
 func TestSubscribe(t *testing.T) {
 	conn := test.NewMockConnection(t)
 	client := ipc.NewClient(conn, binary.LittleEndian)
@@ -226,3 +341,223 @@ func TestSubscribe(t *testing.T) {
 	assert.Nil(t, result)
 	assert.NotNil(t, err)
 }
+
+// replyOnSecondConn serves a sway-ipc socket that never answers
+// on the first connection and echoes the version on later connections.
+func replyOnSecondConn(l net.Listener, reply []byte) <-chan struct{} {
+	firstClosed := make(chan struct{})
+	go func() {
+		first, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			io.Copy(io.Discard, first)
+			close(firstClosed)
+		}()
+
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			var h ipc.Header
+			if err := binary.Read(conn, binary.LittleEndian, &h); err != nil {
+				return
+			}
+			io.CopyN(io.Discard, conn, int64(h.PayloadLength))
+			binary.Write(conn, binary.LittleEndian, ipc.NewHeader(h.PayloadType, len(reply)))
+			conn.Write(reply)
+		}
+	}()
+
+	return firstClosed
+}
+
+func TestCtxDeadlineReconnects(t *testing.T) {
+	tmpsocket := t.TempDir() + "/uds"
+	l, err := net.Listen("unix", tmpsocket)
+	require.Nil(t, err)
+	defer l.Close()
+
+	expected := &ipc.Version{Major: 1, HumanReadable: "TestHumanReadable"}
+	reply, err := json.Marshal(expected)
+	require.Nil(t, err)
+	firstClosed := replyOnSecondConn(l, reply)
+
+	client, err := ipc.ConnectCustom(tmpsocket, binary.LittleEndian)
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.VersionCtx(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	select {
+	case <-firstClosed:
+	case <-time.After(time.Second):
+		t.Fatal("interrupted connection was not closed")
+	}
+
+	actual, err := client.Version()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestSetTimeout(t *testing.T) {
+	tmpsocket := t.TempDir() + "/uds"
+	l, err := net.Listen("unix", tmpsocket)
+	require.Nil(t, err)
+	defer l.Close()
+	replyOnSecondConn(l, []byte("[]"))
+
+	client, err := ipc.ConnectCustom(tmpsocket, binary.LittleEndian)
+	require.Nil(t, err)
+	client.SetTimeout(50 * time.Millisecond)
+
+	_, err = client.Workspaces()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	ws, err := client.Workspaces()
+	assert.Nil(t, err)
+	assert.Empty(t, ws)
+}
+
+func TestCtxCancelWithoutDial(t *testing.T) {
+	server, conn := net.Pipe()
+	defer server.Close()
+	go io.Copy(io.Discard, server)
+
+	client := ipc.NewClient(conn, binary.LittleEndian)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	_, err := client.TreeCtx(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = client.Tree()
+	assert.ErrorIs(t, err, ipc.ErrConnectionReset)
+}
+
+func TestCtxAlreadyCancelled(t *testing.T) {
+	conn := test.NewMockConnection(t)
+	client := ipc.NewClient(conn, binary.LittleEndian)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.CommandCtx(ctx, "testpayload")
+	assert.ErrorIs(t, err, context.Canceled)
+	conn.AssertNotCalled("Write")
+	conn.AssertNotCalled("Close")
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := map[string]struct {
+		cmd      string
+		expected []string
+	}{
+		"Single":    {"splitv", []string{"splitv"}},
+		"Mixed":     {"focus parent; splitv, focus child", []string{"focus parent", "splitv", "focus child"}},
+		"Criteria":  {`[title="a;b, c"] kill; nop`, []string{`[title="a;b, c"] kill`, "nop"}},
+		"Quoted":    {`workspace "x; y", exec 'a,b'`, []string{`workspace "x; y"`, `exec 'a,b'`}},
+		"Escaped":   {`nop a\;b; nop`, []string{`nop a\;b`, "nop"}},
+		"Trailing":  {"kill;", []string{"kill"}},
+		"Empty":     {"", nil},
+		"Bracketed": {"[con_mark=a] focus, [con_mark=b] kill", []string{"[con_mark=a] focus", "[con_mark=b] kill"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ipc.SplitCommand(tc.cmd))
+		})
+	}
+}
+
+func TestSplitCommandGroups(t *testing.T) {
+	tests := map[string]struct {
+		cmd      string
+		expected [][]string
+	}{
+		"Single": {"splitv", [][]string{{"splitv"}}},
+		"Mixed":  {"focus parent; splitv, focus child", [][]string{{"focus parent"}, {"splitv", "focus child"}}},
+		"Quoted": {`[title="a;b"] kill, nop; nop`, [][]string{{`[title="a;b"] kill`, "nop"}, {"nop"}}},
+		"Gaps":   {"; kill,; nop;", [][]string{{"kill"}, {"nop"}}},
+		"Empty":  {"", nil},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ipc.SplitCommandGroups(tc.cmd))
+		})
+	}
+}
+
+func TestSplitCriteria(t *testing.T) {
+	tests := map[string]struct {
+		cmd      string
+		criteria string
+		rest     string
+		ok       bool
+	}{
+		"None":     {"kill", "", "kill", true},
+		"Criteria": {`[app_id="foot"] kill`, `app_id="foot"`, "kill", true},
+		"Quoted":   {`[title="a]b" con_mark='x]'] focus`, `title="a]b" con_mark='x]'`, "focus", true},
+		"Escaped":  {`[title=a\]b] focus`, `title=a\]b`, "focus", true},
+		"Open":     {`[title="a]b" kill`, "", `[title="a]b" kill`, false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			criteria, rest, ok := ipc.SplitCriteria(tc.cmd)
+			assert.Equal(t, tc.criteria, criteria)
+			assert.Equal(t, tc.rest, rest)
+			assert.Equal(t, tc.ok, ok)
+		})
+	}
+}
+
+func TestCommandChecked(t *testing.T) {
+	tests := map[string]struct {
+		cmd      string
+		results  []ipc.Command
+		expected *ipc.CommandError
+	}{
+		"Success": {
+			"focus parent; splitv",
+			[]ipc.Command{{Result: ipc.Result{Success: true}}, {Result: ipc.Result{Success: true}}},
+			nil,
+		},
+		"Failure": {
+			"workspace number 3, exec foot",
+			[]ipc.Command{{Result: ipc.Result{Success: true}}, {Error: "exec failed"}},
+			&ipc.CommandError{Index: 1, Command: "exec foot", Message: "exec failed"},
+		},
+		"ParseError": {
+			"splitx; kill",
+			[]ipc.Command{{ParseError: true, Error: "Unknown/invalid command 'splitx'"}},
+			&ipc.CommandError{Index: 0, Command: "splitx", ParseError: true, Message: "Unknown/invalid command 'splitx'"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			conn := test.NewMockConnection(t)
+			client := ipc.NewClient(conn, binary.LittleEndian)
+			reply, err := json.Marshal(tc.results)
+			require.Nil(t, err)
+			conn.PushPayloadForRead(uint32(ipc.RunCommandMessage), reply, binary.LittleEndian)
+
+			err = client.CommandChecked(tc.cmd)
+			if tc.expected == nil {
+				assert.Nil(t, err)
+				return
+			}
+
+			var cerr *ipc.CommandError
+			require.ErrorAs(t, err, &cerr)
+			assert.Equal(t, tc.expected, cerr)
+			assert.Contains(t, err.Error(), tc.expected.Command)
+		})
+	}
+}
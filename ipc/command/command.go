@@ -0,0 +1,337 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/libanvl/swager/ipc"
+)
+
+// Command is one or more rendered sway commands.
+// Converting a string to a Command sends it unmodified.
+type Command string
+
+func (c Command) String() string {
+	return string(c)
+}
+
+// Then chains next after c, separated by ';'.
+func (c Command) Then(next Command) Command {
+	return join(c, "; ", next)
+}
+
+// And chains next after c, separated by ','.
+// Criteria in front of c also apply to next.
+func (c Command) And(next Command) Command {
+	return join(c, ", ", next)
+}
+
+func join(c Command, sep string, next Command) Command {
+	if c == "" {
+		return next
+	}
+
+	if next == "" {
+		return c
+	}
+
+	return c + Command(sep) + next
+}
+
+// Chain joins cmds with ';'.
+func Chain(cmds ...Command) Command {
+	var c Command
+	for _, next := range cmds {
+		c = c.Then(next)
+	}
+
+	return c
+}
+
+type Direction string
+
+const (
+	Left  Direction = "left"
+	Right Direction = "right"
+	Up    Direction = "up"
+	Down  Direction = "down"
+)
+
+type Orientation string
+
+const (
+	Horizontal Orientation = "h"
+	Vertical   Orientation = "v"
+	// OrientationToggle splits in the opposite orientation of the parent.
+	OrientationToggle Orientation = "t"
+)
+
+// LayoutMode is a layout a container can be set to.
+type LayoutMode string
+
+const (
+	SplitH   LayoutMode = "splith"
+	SplitV   LayoutMode = "splitv"
+	Stacking LayoutMode = "stacking"
+	Tabbed   LayoutMode = "tabbed"
+)
+
+// LayoutModeOf returns the LayoutMode that sets the layout of a container
+// in the tree, or false for layouts a container cannot be set to,
+// like those of outputs and views.
+func LayoutModeOf(l ipc.LayoutType) (LayoutMode, bool) {
+	switch l {
+	case ipc.SplitHLayout:
+		return SplitH, true
+	case ipc.SplitVLayout:
+		return SplitV, true
+	case ipc.StackedLayout:
+		// the tree calls it stacked, the command stacking
+		return Stacking, true
+	case ipc.TabbedLayout:
+		return Tabbed, true
+	}
+
+	return "", false
+}
+
+// Switch is the argument of commands that enable,
+// disable or toggle a container state.
+type Switch string
+
+const (
+	Enable  Switch = "enable"
+	Disable Switch = "disable"
+	Toggle  Switch = "toggle"
+)
+
+type ResizeAction string
+
+const (
+	Grow   ResizeAction = "grow"
+	Shrink ResizeAction = "shrink"
+	Set    ResizeAction = "set"
+)
+
+type Dimension string
+
+const (
+	Width  Dimension = "width"
+	Height Dimension = "height"
+)
+
+type Unit string
+
+const (
+	Px  Unit = "px"
+	Ppt Unit = "ppt"
+)
+
+// Focus moves focus to the container in the given direction.
+func Focus(d Direction) Command {
+	return build("focus", string(d))
+}
+
+// FocusParent moves focus to the parent container.
+func FocusParent() Command {
+	return build("focus", "parent")
+}
+
+// FocusChild moves focus to the last focused child container.
+func FocusChild() Command {
+	return build("focus", "child")
+}
+
+// FocusOutput moves focus to the named output.
+func FocusOutput(name string) Command {
+	return build("focus", "output", quote(name))
+}
+
+// FocusFloating moves focus to the last focused floating container.
+func FocusFloating() Command {
+	return build("focus", "floating")
+}
+
+// FocusTiling moves focus to the last focused tiling container.
+func FocusTiling() Command {
+	return build("focus", "tiling")
+}
+
+// Move moves the focused container in the given direction.
+func Move(d Direction) Command {
+	return build("move", string(d))
+}
+
+// MoveBy moves the focused container px pixels in the given direction.
+func MoveBy(d Direction, px int) Command {
+	return build("move", string(d), strconv.Itoa(px), string(Px))
+}
+
+// MoveToWorkspace moves the focused container to the named workspace.
+func MoveToWorkspace(name string) Command {
+	return build("move", "container", "to", "workspace", quote(name))
+}
+
+// MoveToWorkspaceNumber moves the focused container to the
+// workspace with the given number.
+func MoveToWorkspaceNumber(num int) Command {
+	return build("move", "container", "to", "workspace", "number", strconv.Itoa(num))
+}
+
+// MoveToOutput moves the focused container to the named output.
+func MoveToOutput(name string) Command {
+	return build("move", "container", "to", "output", quote(name))
+}
+
+// MoveToMark moves the focused container to the container with the mark.
+func MoveToMark(mark string) Command {
+	return build("move", "container", "to", "mark", quote(mark))
+}
+
+// MoveToScratchpad moves the focused container to the scratchpad.
+func MoveToScratchpad() Command {
+	return build("move", "scratchpad")
+}
+
+// Split splits the focused container with the given orientation.
+func Split(o Orientation) Command {
+	return build("split" + string(o))
+}
+
+// Layout sets the layout of the focused container.
+func Layout(m LayoutMode) Command {
+	return build("layout", string(m))
+}
+
+// LayoutToggleSplit toggles the focused container between splith and splitv.
+func LayoutToggleSplit() Command {
+	return build("layout", "toggle", "split")
+}
+
+// Resize resizes the focused container.
+func Resize(a ResizeAction, d Dimension, amount int, u Unit) Command {
+	return build("resize", string(a), string(d), strconv.Itoa(amount), string(u))
+}
+
+// Mark adds mark to the focused container, replacing its other marks.
+func Mark(mark string) Command {
+	return build("mark", quote(mark))
+}
+
+// MarkAdd adds mark to the focused container, keeping its other marks.
+func MarkAdd(mark string) Command {
+	return build("mark", "--add", quote(mark))
+}
+
+// MarkToggle toggles mark on the focused container.
+func MarkToggle(mark string) Command {
+	return build("mark", "--add", "--toggle", quote(mark))
+}
+
+// Unmark removes mark from all containers.
+// An empty mark removes all marks.
+func Unmark(mark string) Command {
+	if mark == "" {
+		return build("unmark")
+	}
+
+	return build("unmark", quote(mark))
+}
+
+// SwapWithConID swaps the focused container with the container with the id.
+func SwapWithConID(id int) Command {
+	return build("swap", "container", "with", "con_id", strconv.Itoa(id))
+}
+
+// SwapWithMark swaps the focused container with the container with the mark.
+func SwapWithMark(mark string) Command {
+	return build("swap", "container", "with", "mark", quote(mark))
+}
+
+// Workspace switches to the named workspace.
+func Workspace(name string) Command {
+	return build("workspace", quote(name))
+}
+
+// WorkspaceNumber switches to the workspace with the given number.
+func WorkspaceNumber(num int) Command {
+	return build("workspace", "number", strconv.Itoa(num))
+}
+
+// WorkspaceNext switches to the next workspace on the output.
+func WorkspaceNext() Command {
+	return build("workspace", "next_on_output")
+}
+
+// WorkspacePrev switches to the previous workspace on the output.
+func WorkspacePrev() Command {
+	return build("workspace", "prev_on_output")
+}
+
+// Exec runs cmd with sh -c. Sway passes cmd to the shell as is,
+// so cmd must not contain unquoted ';' or ',' characters.
+func Exec(cmd string) Command {
+	return build("exec", cmd)
+}
+
+// ExecArgs runs the program with the given arguments,
+// quoting each argument for the shell.
+func ExecArgs(name string, args ...string) Command {
+	words := make([]string, 0, len(args)+2)
+	words = append(words, "exec", shellquote(name))
+	for _, a := range args {
+		words = append(words, shellquote(a))
+	}
+
+	return build(words...)
+}
+
+// Floating sets whether the focused container is floating.
+func Floating(s Switch) Command {
+	return build("floating", string(s))
+}
+
+// Fullscreen sets whether the focused container is fullscreen.
+func Fullscreen(s Switch) Command {
+	return build("fullscreen", string(s))
+}
+
+// Sticky sets whether the focused floating container is sticky.
+func Sticky(s Switch) Command {
+	return build("sticky", string(s))
+}
+
+// Kill closes the focused container.
+func Kill() Command {
+	return build("kill")
+}
+
+// Nop does nothing. The comment is ignored by sway.
+func Nop(comment string) Command {
+	return build("nop", quote(comment))
+}
+
+func build(words ...string) Command {
+	return Command(strings.Join(words, " "))
+}
+
+// quote returns s as a single sway command argument.
+func quote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\;,[]") {
+		return s
+	}
+
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return fmt.Sprintf(`"%s"`, r.Replace(s))
+}
+
+// shellquote returns s as a single sh argument
+// that sway passes through unsplit.
+func shellquote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n\"'\\;,[]$`&|<>(){}*?#~!") {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
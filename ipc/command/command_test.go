@@ -0,0 +1,97 @@
+package command_test
+
+import (
+	"testing"
+
+	"github.com/libanvl/swager/ipc"
+	"github.com/libanvl/swager/ipc/command"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommands(t *testing.T) {
+	tests := map[string]struct {
+		cmd      command.Command
+		expected string
+	}{
+		"Focus":                 {command.Focus(command.Left), "focus left"},
+		"FocusParent":           {command.FocusParent(), "focus parent"},
+		"FocusChild":            {command.FocusChild(), "focus child"},
+		"FocusOutput":           {command.FocusOutput("HDMI-A-1"), "focus output HDMI-A-1"},
+		"Move":                  {command.Move(command.Down), "move down"},
+		"MoveBy":                {command.MoveBy(command.Up, 10), "move up 10 px"},
+		"MoveToWorkspace":       {command.MoveToWorkspace("my ws"), `move container to workspace "my ws"`},
+		"MoveToWorkspaceNumber": {command.MoveToWorkspaceNumber(3), "move container to workspace number 3"},
+		"MoveToMark":            {command.MoveToMark("a"), "move container to mark a"},
+		"MoveToScratchpad":      {command.MoveToScratchpad(), "move scratchpad"},
+		"SplitH":                {command.Split(command.Horizontal), "splith"},
+		"SplitV":                {command.Split(command.Vertical), "splitv"},
+		"SplitT":                {command.Split(command.OrientationToggle), "splitt"},
+		"Layout":                {command.Layout(command.Tabbed), "layout tabbed"},
+		"LayoutSplitV":          {command.Layout(command.SplitV), "layout splitv"},
+		"LayoutStacking":        {command.Layout(command.Stacking), "layout stacking"},
+		"LayoutToggleSplit":     {command.LayoutToggleSplit(), "layout toggle split"},
+		"Resize":                {command.Resize(command.Grow, command.Width, 10, command.Ppt), "resize grow width 10 ppt"},
+		"Mark":                  {command.Mark("main"), "mark main"},
+		"MarkAdd":               {command.MarkAdd("main"), "mark --add main"},
+		"MarkToggle":            {command.MarkToggle("main"), "mark --add --toggle main"},
+		"Unmark":                {command.Unmark("main"), "unmark main"},
+		"UnmarkAll":             {command.Unmark(""), "unmark"},
+		"SwapWithConID":         {command.SwapWithConID(42), "swap container with con_id 42"},
+		"SwapWithMark":          {command.SwapWithMark("main"), "swap container with mark main"},
+		"Workspace":             {command.Workspace("1:web"), "workspace 1:web"},
+		"WorkspaceQuoted":       {command.Workspace(`say "hi"; bye`), `workspace "say \"hi\"; bye"`},
+		"WorkspaceEmpty":        {command.Workspace(""), `workspace ""`},
+		"WorkspaceNumber":       {command.WorkspaceNumber(5), "workspace number 5"},
+		"Exec":                  {command.Exec("foot -e htop"), "exec foot -e htop"},
+		"ExecArgs":              {command.ExecArgs("notify-send", "it's done; really"), `exec notify-send 'it'\''s done; really'`},
+		"Floating":              {command.Floating(command.Toggle), "floating toggle"},
+		"Fullscreen":            {command.Fullscreen(command.Enable), "fullscreen enable"},
+		"Sticky":                {command.Sticky(command.Disable), "sticky disable"},
+		"Kill":                  {command.Kill(), "kill"},
+		"Raw":                   {command.Command("reload"), "reload"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.cmd.String())
+		})
+	}
+}
+
+func TestChaining(t *testing.T) {
+	cmd := command.FocusParent().
+		Then(command.Split(command.Vertical)).
+		And(command.FocusChild())
+	assert.Equal(t, "focus parent; splitv, focus child", cmd.String())
+
+	chain := command.Chain(command.WorkspaceNumber(1), command.Exec("foot"), command.Command(""))
+	assert.Equal(t, "workspace number 1; exec foot", chain.String())
+
+	assert.Equal(t, "kill", command.Command("").And(command.Kill()).String())
+}
+
+func TestLayoutModeOf(t *testing.T) {
+	tests := map[string]struct {
+		layout   ipc.LayoutType
+		expected command.LayoutMode
+		ok       bool
+	}{
+		"SplitH":  {ipc.SplitHLayout, command.SplitH, true},
+		"SplitV":  {ipc.SplitVLayout, command.SplitV, true},
+		"Stacked": {ipc.StackedLayout, command.Stacking, true},
+		"Tabbed":  {ipc.TabbedLayout, command.Tabbed, true},
+		"Output":  {ipc.OutputLayout, "", false},
+		"None":    {"none", "", false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mode, ok := command.LayoutModeOf(tc.layout)
+			assert.Equal(t, tc.expected, mode)
+			assert.Equal(t, tc.ok, ok)
+		})
+	}
+
+	mode, _ := command.LayoutModeOf(ipc.StackedLayout)
+	assert.Equal(t, "layout stacking", command.Layout(mode).String())
+}
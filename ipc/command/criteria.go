@@ -0,0 +1,317 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/libanvl/swager/ipc"
+)
+
+// Criteria selects the containers a command applies to,
+// using the same rules as sway criteria.
+// Empty fields are not part of the criteria. AppID, Class, Instance,
+// Title, ConMark and Workspace are regular expressions.
+type Criteria struct {
+	AppID     string
+	Class     string
+	Instance  string
+	Title     string
+	Shell     string
+	ConID     int
+	ConMark   string
+	Workspace string
+	Pid       int
+	Floating  bool
+	Tiling    bool
+	Urgent    Urgency
+}
+
+// Urgency selects among urgent windows.
+type Urgency string
+
+const (
+	LatestUrgent Urgency = "latest"
+	OldestUrgent Urgency = "oldest"
+)
+
+// Apply prefixes cmd with the criteria.
+// The criteria apply to cmd and every command chained to it with And.
+// Apply fails for criteria that Validate rejects.
+func (c Criteria) Apply(cmd Command) (Command, error) {
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+
+	prefix := c.String()
+	if prefix == "" {
+		return cmd, nil
+	}
+
+	return Command(prefix + " " + cmd.String()), nil
+}
+
+// Validate reports values that cannot be sent to sway unchanged.
+// Sway ends a quoted value at the first double quote not preceded by
+// a backslash, so a value cannot end with a backslash.
+func (c Criteria) Validate() error {
+	for _, v := range [][2]string{
+		{"app_id", c.AppID},
+		{"class", c.Class},
+		{"instance", c.Instance},
+		{"title", c.Title},
+		{"shell", c.Shell},
+		{"con_mark", c.ConMark},
+		{"workspace", c.Workspace},
+	} {
+		if strings.HasSuffix(v[1], `\`) {
+			return fmt.Errorf("command: criteria %s %q ends with a backslash", v[0], v[1])
+		}
+	}
+
+	return nil
+}
+
+// String renders the criteria in sway syntax, for example
+// [app_id="^foot$" floating]. Empty criteria render as "".
+// String does not check the values, see Validate.
+func (c Criteria) String() string {
+	var parts []string
+	attr := func(name string, value string) {
+		if value != "" {
+			parts = append(parts, fmt.Sprintf(`%s="%s"`, name, escapeCriteria(value)))
+		}
+	}
+
+	attr("app_id", c.AppID)
+	attr("class", c.Class)
+	attr("instance", c.Instance)
+	attr("title", c.Title)
+	attr("shell", c.Shell)
+	if c.ConID != 0 {
+		parts = append(parts, "con_id="+strconv.Itoa(c.ConID))
+	}
+	attr("con_mark", c.ConMark)
+	attr("workspace", c.Workspace)
+	if c.Pid != 0 {
+		parts = append(parts, "pid="+strconv.Itoa(c.Pid))
+	}
+	if c.Floating {
+		parts = append(parts, "floating")
+	}
+	if c.Tiling {
+		parts = append(parts, "tiling")
+	}
+	if c.Urgent != "" {
+		parts = append(parts, "urgent="+string(c.Urgent))
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+// Predicate compiles the criteria into a func usable as a
+// node.NodePredicate. root is the tree the matched nodes belong to;
+// it is only needed to resolve the Workspace and Tiling criteria.
+// Urgent matches any urgent container. Tiling matches views,
+// containers without children, that are not inside a floating container.
+// Like Apply, Predicate fails for criteria that Validate rejects.
+func (c Criteria) Predicate(root *ipc.Node) (func(*ipc.Node) bool, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	var matchers []func(*ipc.Node) bool
+
+	str := func(expr string, field func(*ipc.Node) *string) error {
+		if expr == "" {
+			return nil
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return err
+		}
+
+		matchers = append(matchers, func(n *ipc.Node) bool {
+			s := field(n)
+			return s != nil && re.MatchString(*s)
+		})
+
+		return nil
+	}
+
+	props := func(get func(*ipc.WindowProperties) string) func(*ipc.Node) *string {
+		return func(n *ipc.Node) *string {
+			if n.WindowProperties == nil {
+				return nil
+			}
+
+			s := get(n.WindowProperties)
+			return &s
+		}
+	}
+
+	for _, err := range []error{
+		str(c.AppID, func(n *ipc.Node) *string { return n.AppID }),
+		str(c.Class, props(func(p *ipc.WindowProperties) string { return p.Class })),
+		str(c.Instance, props(func(p *ipc.WindowProperties) string { return p.Instance })),
+		str(c.Title, func(n *ipc.Node) *string {
+			if n.Type != ipc.ConNode && n.Type != ipc.FloatingConNode {
+				return nil
+			}
+
+			return &n.Name
+		}),
+		str(c.Shell, func(n *ipc.Node) *string { return n.Shell }),
+		str(c.Workspace, workspaceOf(root)),
+	} {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.ConMark != "" {
+		re, err := regexp.Compile(c.ConMark)
+		if err != nil {
+			return nil, err
+		}
+
+		matchers = append(matchers, func(n *ipc.Node) bool {
+			for _, m := range n.Marks {
+				if re.MatchString(m) {
+					return true
+				}
+			}
+
+			return false
+		})
+	}
+
+	if c.ConID != 0 {
+		matchers = append(matchers, func(n *ipc.Node) bool { return n.ID == c.ConID })
+	}
+
+	if c.Pid != 0 {
+		matchers = append(matchers, func(n *ipc.Node) bool { return n.Pid != nil && *n.Pid == c.Pid })
+	}
+
+	if c.Floating {
+		matchers = append(matchers, func(n *ipc.Node) bool { return n.Type == ipc.FloatingConNode })
+	}
+
+	if c.Tiling {
+		floating := floatingIn(root)
+		matchers = append(matchers, func(n *ipc.Node) bool {
+			return n.Type == ipc.ConNode && len(n.Nodes) == 0 && !floating[n.ID]
+		})
+	}
+
+	if c.Urgent != "" {
+		matchers = append(matchers, func(n *ipc.Node) bool { return n.Urgent != nil && *n.Urgent })
+	}
+
+	return func(n *ipc.Node) bool {
+		if n == nil {
+			return false
+		}
+
+		for _, m := range matchers {
+			if !m(n) {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
+
+// workspaceOf returns the name of the workspace containing a node of root.
+func workspaceOf(root *ipc.Node) func(*ipc.Node) *string {
+	names := make(map[int]string)
+
+	var walk func(n *ipc.Node, ws *string)
+	walk = func(n *ipc.Node, ws *string) {
+		if n.Type == ipc.WorkspaceNode {
+			ws = &n.Name
+		}
+
+		if ws != nil {
+			names[n.ID] = *ws
+		}
+
+		for _, child := range n.Nodes {
+			walk(child, ws)
+		}
+
+		for _, child := range n.FloatingNodes {
+			walk(child, ws)
+		}
+	}
+
+	if root != nil {
+		walk(root, nil)
+	}
+
+	return func(n *ipc.Node) *string {
+		if name, ok := names[n.ID]; ok {
+			return &name
+		}
+
+		return nil
+	}
+}
+
+// floatingIn returns the ids of the containers of root
+// that are inside a floating container.
+func floatingIn(root *ipc.Node) map[int]bool {
+	ids := make(map[int]bool)
+	var walk func(n *ipc.Node, floating bool)
+	walk = func(n *ipc.Node, floating bool) {
+		if floating {
+			ids[n.ID] = true
+		}
+
+		floating = floating || n.Type == ipc.FloatingConNode
+		for _, child := range n.Nodes {
+			walk(child, floating)
+		}
+
+		for _, child := range n.FloatingNodes {
+			walk(child, floating)
+		}
+	}
+
+	if root != nil {
+		walk(root, false)
+	}
+
+	return ids
+}
+
+// escapeCriteria escapes the double quotes that would end the value.
+// A quote already escaped for the regex is left as is, other quotes get
+// a backslash, which the regex engine ignores in front of a quote.
+func escapeCriteria(s string) string {
+	var b strings.Builder
+	backslashes := 0
+	for _, r := range s {
+		if r == '"' && backslashes%2 == 0 {
+			b.WriteByte('\\')
+		}
+
+		if r == '\\' {
+			backslashes++
+		} else {
+			backslashes = 0
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,113 @@
+package command_test
+
+import (
+	"testing"
+
+	"github.com/libanvl/swager/internal/core/node"
+	"github.com/libanvl/swager/ipc"
+	"github.com/libanvl/swager/ipc/command"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCriteriaString(t *testing.T) {
+	tests := map[string]struct {
+		criteria command.Criteria
+		expected string
+	}{
+		"Empty":        {command.Criteria{}, ""},
+		"AppID":        {command.Criteria{AppID: "^foot$"}, `[app_id="^foot$"]`},
+		"Escaped":      {command.Criteria{Title: `say "hi"`}, `[title="say \"hi\""]`},
+		"EscapedQuote": {command.Criteria{Title: `say \"hi\\"`}, `[title="say \"hi\\\""]`},
+		"Backslash":    {command.Criteria{Title: `C:\\d`, Class: "x"}, `[class="x" title="C:\\d"]`},
+		"Flags":        {command.Criteria{Floating: true, Urgent: command.LatestUrgent}, "[floating urgent=latest]"},
+		"Multiple":     {command.Criteria{Class: "Firefox", ConID: 7, Pid: 42}, `[class="Firefox" con_id=7 pid=42]`},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.criteria.String())
+		})
+	}
+}
+
+func TestCriteriaApply(t *testing.T) {
+	crit := command.Criteria{ConMark: "main"}
+	cmd, err := crit.Apply(command.Focus(command.Left).And(command.Kill()))
+	require.Nil(t, err)
+	assert.Equal(t, `[con_mark="main"] focus left, kill`, cmd.String())
+
+	cmd, err = command.Criteria{}.Apply(command.Kill())
+	require.Nil(t, err)
+	assert.Equal(t, "kill", cmd.String())
+}
+
+func TestCriteriaTrailingBackslash(t *testing.T) {
+	for _, crit := range []command.Criteria{{Title: `C:\`}, {ConMark: `a\\`}} {
+		_, err := crit.Apply(command.Kill())
+		assert.NotNil(t, err, crit.String())
+		_, err = crit.Predicate(nil)
+		assert.NotNil(t, err, crit.String())
+	}
+}
+
+func TestCriteriaPredicate(t *testing.T) {
+	foot, firefox := "foot", "firefox"
+	pid := 42
+	urgent := true
+	footNode := &ipc.Node{ID: 4, Name: "htop", Type: ipc.ConNode, AppID: &foot, Pid: &pid, Marks: []string{"main"}}
+	firefoxNode := &ipc.Node{ID: 5, Name: "Mozilla Firefox", Type: ipc.FloatingConNode, AppID: &firefox, Urgent: &urgent}
+	xNode := &ipc.Node{ID: 6, Name: "xterm", Type: ipc.ConNode,
+		WindowProperties: &ipc.WindowProperties{Class: "XTerm", Instance: "xterm"}}
+	split := &ipc.Node{ID: 7, Type: ipc.ConNode, Nodes: []*ipc.Node{{ID: 8, Name: `C:\`, Type: ipc.ConNode}}}
+	floatingSplit := &ipc.Node{ID: 9, Type: ipc.FloatingConNode, Nodes: []*ipc.Node{{ID: 10, Type: ipc.ConNode}}}
+	root := &ipc.Node{ID: 1, Type: ipc.RootNode, Nodes: []*ipc.Node{
+		{ID: 2, Type: ipc.OutputNode, Nodes: []*ipc.Node{
+			{ID: 3, Name: "1", Type: ipc.WorkspaceNode,
+				Nodes:         []*ipc.Node{footNode, xNode, split},
+				FloatingNodes: []*ipc.Node{firefoxNode, floatingSplit}},
+		}},
+	}}
+
+	tests := map[string]struct {
+		criteria command.Criteria
+		expected []int
+	}{
+		"AppID":        {command.Criteria{AppID: "^f"}, []int{4, 5}},
+		"Class":        {command.Criteria{Class: "^XTerm$"}, []int{6}},
+		"Instance":     {command.Criteria{Instance: "xterm"}, []int{6}},
+		"Title":        {command.Criteria{Title: "(?i)firefox"}, []int{5}},
+		"ConID":        {command.Criteria{ConID: 6}, []int{6}},
+		"ConMark":      {command.Criteria{ConMark: "^ma"}, []int{4}},
+		"Pid":          {command.Criteria{Pid: 42}, []int{4}},
+		"Floating":     {command.Criteria{Floating: true}, []int{5, 9}},
+		"Tiling":       {command.Criteria{Tiling: true}, []int{4, 6, 8}},
+		"Backslash":    {command.Criteria{Title: `:\\$`}, []int{8}},
+		"Urgent":       {command.Criteria{Urgent: command.OldestUrgent}, []int{5}},
+		"Workspace":    {command.Criteria{Workspace: "^1$", AppID: "."}, []int{4, 5}},
+		"Intersection": {command.Criteria{AppID: "^f", Tiling: true}, []int{4}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			pred, err := tc.criteria.Predicate(root)
+			require.Nil(t, err)
+
+			var matched []int
+			node.Count(root, func(n *ipc.Node) bool {
+				if pred(n) {
+					matched = append(matched, n.ID)
+				}
+				return false
+			})
+
+			assert.ElementsMatch(t, tc.expected, matched)
+			assert.Equal(t, tc.expected[0], node.First(root, pred).ID)
+		})
+	}
+}
+
+func TestCriteriaPredicateInvalid(t *testing.T) {
+	_, err := command.Criteria{Title: "("}.Predicate(nil)
+	assert.NotNil(t, err)
+}
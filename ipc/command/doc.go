@@ -0,0 +1,32 @@
+/*
+Package command builds sway commands for the sway-ipc RUN_COMMAND message.
+
+Each builder returns a Command, which renders to the exact string passed
+to ipc.Client.Command. Arguments such as workspace names and marks are quoted
+and escaped as needed, so values containing spaces, quotes or command
+separators stay a single argument.
+
+Commands are chained with Then, which joins them with ';', and And, which
+joins them with ','. The two only differ when the chain is prefixed
+with criteria: criteria apply to every command up to the next ';'.
+
+Criteria selects the containers a command applies to. Criteria.Apply renders
+it as the [...] prefix of a command, and Criteria.Predicate evaluates the
+same rules locally against an *ipc.Node, so a block can use one description
+both to find windows in a tree and to command them.
+
+Example
+
+	cmd := command.FocusParent().
+		Then(command.Split(command.Vertical)).
+		And(command.FocusChild())
+
+	client.Command(cmd.String()) // "focus parent; splitv, focus child"
+
+	terms := command.Criteria{AppID: "^foot$"}
+	floating, err := terms.Apply(command.Floating(command.Enable))
+	if err == nil {
+		client.Command(floating.String())
+	}
+*/
+package command
@@ -0,0 +1,202 @@
+package ipc
+
+import (
+	"sync"
+	"time"
+)
+
+// DeliveryMode selects how a Subscription calls its handlers.
+type DeliveryMode int8
+
+const (
+	// ConcurrentDelivery calls each handler in a new goroutine for every event.
+	// Handlers may see events out of order. This is the default.
+	ConcurrentDelivery DeliveryMode = 0
+	// OrderedDelivery gives each handler a queue, drained by a goroutine of
+	// its own, so every handler gets its events one at a time in the order
+	// sway sent them.
+	OrderedDelivery DeliveryMode = 1
+)
+
+// SlowPolicy decides what happens to a new event when the queue
+// of a handler is full.
+type SlowPolicy int8
+
+const (
+	// BlockPolicy makes Run wait until the handler made room in its queue.
+	// No event is lost, but a slow handler delays every other handler.
+	BlockPolicy SlowPolicy = 0
+	// DropOldestPolicy discards the oldest queued event to make room.
+	DropOldestPolicy SlowPolicy = 1
+	// CoalescePolicy keeps only the newest event: a handler that was busy
+	// gets the latest event that arrived meanwhile, and none before it.
+	// Buffer is ignored.
+	CoalescePolicy SlowPolicy = 2
+)
+
+// Delivery configures how a Subscription calls its handlers.
+type Delivery struct {
+	Mode DeliveryMode
+	// Buffer is the number of events queued for each handler
+	// in OrderedDelivery mode. It is at least one.
+	Buffer int
+	Policy SlowPolicy
+}
+
+// SetDelivery sets how the Subscription calls the handlers
+// registered after it. Call it before registering handlers.
+func (s *Subscription) SetDelivery(d Delivery) {
+	if d.Buffer < 1 {
+		d.Buffer = 1
+	}
+
+	s.delivery = d
+}
+
+// DefaultDrainTimeout is how long Run waits for the handlers
+// still running when it returns, unless SetDrainTimeout changed it.
+const DefaultDrainTimeout = 5 * time.Second
+
+// SetDrainTimeout sets how long Run waits for the handlers still
+// running, or still queued with OrderedDelivery, when it returns.
+// Zero makes Run return without waiting.
+func (s *Subscription) SetDrainTimeout(d time.Duration) {
+	s.drain = d
+}
+
+// tracker counts the events handed to handlers
+// that the handlers have not finished with.
+type tracker struct {
+	mx   sync.Mutex
+	n    int
+	idle chan struct{}
+}
+
+func (t *tracker) add(n int) {
+	if n == 0 {
+		return
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	if t.n == 0 {
+		t.idle = make(chan struct{})
+	}
+
+	t.n += n
+	if t.n == 0 {
+		close(t.idle)
+	}
+}
+
+func (t *tracker) done() {
+	t.add(-1)
+}
+
+// wait waits at most d for the count to drop to zero
+// and returns what is left.
+func (t *tracker) wait(d time.Duration) int {
+	t.mx.Lock()
+	n, idle := t.n, t.idle
+	t.mx.Unlock()
+
+	if n == 0 || d <= 0 {
+		return n
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-idle:
+	case <-timer.C:
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.n
+}
+
+// queue holds the pending events of a single handler
+// in OrderedDelivery mode.
+type queue[E EventArgs] struct {
+	mx     sync.Mutex
+	cond   *sync.Cond
+	items  []E
+	limit  int
+	policy SlowPolicy
+	closed bool
+	t      *tracker
+}
+
+// newQueue starts delivering the events pushed to the queue to h.
+func newQueue[E EventArgs](h func(E), d Delivery, t *tracker) *queue[E] {
+	q := &queue[E]{limit: d.Buffer, policy: d.Policy, t: t}
+	q.cond = sync.NewCond(&q.mx)
+	go q.run(h)
+	return q
+}
+
+func (q *queue[E]) push(e E) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	dropped := 0
+	switch q.policy {
+	case CoalescePolicy:
+		dropped = len(q.items)
+		q.items = q.items[:0]
+	case DropOldestPolicy:
+		if len(q.items) >= q.limit {
+			dropped = 1
+			q.items = q.items[1:]
+		}
+	default:
+		for len(q.items) >= q.limit && !q.closed {
+			q.cond.Wait()
+		}
+	}
+
+	if q.closed {
+		q.t.add(-dropped)
+		return
+	}
+
+	q.items = append(q.items, e)
+	q.t.add(1 - dropped)
+	q.cond.Broadcast()
+}
+
+func (q *queue[E]) run(h func(E)) {
+	for {
+		q.mx.Lock()
+		for len(q.items) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+
+		if q.closed {
+			q.mx.Unlock()
+			return
+		}
+
+		e := q.items[0]
+		q.items = q.items[1:]
+		q.cond.Broadcast()
+		q.mx.Unlock()
+
+		h(e)
+		q.t.done()
+	}
+}
+
+// close stops the queue, discarding the pending events.
+func (q *queue[E]) close() {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	q.closed = true
+	q.t.add(-len(q.items))
+	q.items = nil
+	q.cond.Broadcast()
+}
@@ -0,0 +1,52 @@
+package ipc
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Envelope describes how a Subscription received an event.
+type Envelope struct {
+	// Seq numbers the events received by the Subscription,
+	// starting at one, in the order sway sent them.
+	Seq uint64
+	// Received is when the event was read from the connection.
+	Received time.Time
+	// Size is the length of the payload in bytes.
+	Size int
+}
+
+// Age returns how long ago the event was received.
+func (e Envelope) Age() time.Duration {
+	return time.Since(e.Received)
+}
+
+// Enveloped registers a new event handler that gets the events of the
+// given types, or every sway event the Subscription receives when none
+// are given, decoded into an Event with its Envelope set.
+func (s *Subscription) Enveloped(types []EventPayloadType, h func(Event)) (Cookie, error) {
+	return s.addRaw(types, func(e RawEvent) {
+		if len(types) == 0 && !containsEvent(allEvents, e.Type) {
+			return
+		}
+
+		evt, err := decodeEvent(e.Type, e.Payload)
+		if err != nil {
+			s.sendError(&MonitoringError{fmt.Errorf("Enveloped %v: %s", e.Type, err)})
+			return
+		}
+
+		evt.Envelope = e.Envelope
+		h(evt)
+	})
+}
+
+// envelope numbers an event that was just read.
+func (s *Subscription) envelope(buf []byte) Envelope {
+	return Envelope{
+		Seq:      atomic.AddUint64(&s.seq, 1),
+		Received: time.Now(),
+		Size:     len(buf),
+	}
+}
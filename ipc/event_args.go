@@ -1,7 +1,13 @@
 package ipc
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 type EventArgs interface {
-	WorkspaceChange | ModeChange | WindowChange | BindingChange | ShutdownChange | Tick
+	WorkspaceChange | OutputChange | ModeChange | WindowChange | BarConfigUpdate | BindingChange |
+		ShutdownChange | Tick | BarStateUpdate | InputChange | Reconnected | RawEvent
 }
 
 type WorkspaceChange struct {
@@ -10,6 +16,10 @@ type WorkspaceChange struct {
 	Old     *Node               `json:"old"`
 }
 
+type OutputChange struct {
+	Change OutputChangeType `json:"change"`
+}
+
 type ModeChange struct {
 	// Change is the custom name of the activated mode
 	Change      string `json:"change"`
@@ -21,7 +31,10 @@ type WindowChange struct {
 	Container Node             `json:"container"`
 }
 
-// Barconfig_Update is not implemented
+// BarConfigUpdate carries the full, updated configuration of a bar.
+type BarConfigUpdate struct {
+	BarConfig
+}
 
 type BindingChange struct {
 	Change         BindingChangeType `json:"change"`
@@ -41,5 +54,111 @@ type Tick struct {
 	Payload string `json:"payload"`
 }
 
-// BarState_Update is not implemented
-// Input is not implemented
+type BarStateUpdate struct {
+	ID                string `json:"id"`
+	VisibleByModifier bool   `json:"visible_by_modifier"`
+}
+
+type InputChange struct {
+	Change InputChangeType `json:"change"`
+	Input  Input           `json:"input"`
+}
+
+// RawEvent is an event as sent by sway, before decoding.
+type RawEvent struct {
+	Type     EventPayloadType
+	Payload  json.RawMessage
+	Envelope Envelope
+}
+
+// Event is a single sway event of any type, as delivered by
+// Subscription.Events. Type tells which one of the other fields is set.
+type Event struct {
+	Type      EventPayloadType
+	Workspace *WorkspaceChange
+	Output    *OutputChange
+	Mode      *ModeChange
+	Window    *WindowChange
+	BarConfig *BarConfigUpdate
+	Binding   *BindingChange
+	Shutdown  *ShutdownChange
+	Tick      *Tick
+	BarState  *BarStateUpdate
+	Input     *InputChange
+	// Envelope tells when and in which order the event was received.
+	Envelope Envelope
+}
+
+// Args returns the typed args of the Event, such as a *WindowChange.
+func (e Event) Args() any {
+	switch e.Type {
+	case WorkspaceEvent:
+		return e.Workspace
+	case OutputEvent:
+		return e.Output
+	case ModeEvent:
+		return e.Mode
+	case WindowEvent:
+		return e.Window
+	case BarconfigUpdateEvent:
+		return e.BarConfig
+	case BindingEvent:
+		return e.Binding
+	case ShutdownEvent:
+		return e.Shutdown
+	case TickEvent:
+		return e.Tick
+	case BarStateUpdateEvent:
+		return e.BarState
+	case InputEvent:
+		return e.Input
+	}
+
+	return nil
+}
+
+// decodeEvent decodes the payload of an event of type ept.
+func decodeEvent(ept EventPayloadType, buf []byte) (Event, error) {
+	e := Event{Type: ept}
+	var args any
+	switch ept {
+	case WorkspaceEvent:
+		e.Workspace = new(WorkspaceChange)
+		args = e.Workspace
+	case OutputEvent:
+		e.Output = new(OutputChange)
+		args = e.Output
+	case ModeEvent:
+		e.Mode = new(ModeChange)
+		args = e.Mode
+	case WindowEvent:
+		e.Window = new(WindowChange)
+		args = e.Window
+	case BarconfigUpdateEvent:
+		e.BarConfig = new(BarConfigUpdate)
+		args = e.BarConfig
+	case BindingEvent:
+		e.Binding = new(BindingChange)
+		args = e.Binding
+	case ShutdownEvent:
+		e.Shutdown = new(ShutdownChange)
+		args = e.Shutdown
+	case TickEvent:
+		e.Tick = new(Tick)
+		args = e.Tick
+	case BarStateUpdateEvent:
+		e.BarState = new(BarStateUpdate)
+		args = e.BarState
+	case InputEvent:
+		e.Input = new(InputChange)
+		args = e.Input
+	default:
+		return e, fmt.Errorf("unknown event type: %v", ept)
+	}
+
+	if err := json.Unmarshal(buf, args); err != nil {
+		return e, err
+	}
+
+	return e, nil
+}
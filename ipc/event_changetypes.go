@@ -12,6 +12,14 @@ const (
 	ReloadWorkspace WorkspaceChangeType = "reload"
 )
 
+type OutputChangeType string
+
+const (
+	// UnspecifiedOutput is currently the only change type sway sends.
+	// Query Outputs to find what changed.
+	UnspecifiedOutput OutputChangeType = "unspecified"
+)
+
 type WindowChangeType string
 
 const (
@@ -44,3 +52,13 @@ type ShutdownChangeType string
 const (
 	ExitShutdown ShutdownChangeType = "exit"
 )
+
+type InputChangeType string
+
+const (
+	AddedInput          InputChangeType = "added"
+	RemovedInput        InputChangeType = "removed"
+	XkbKeymapInput      InputChangeType = "xkb_keymap"
+	XkbLayoutInput      InputChangeType = "xkb_layout"
+	LibinputConfigInput InputChangeType = "libinput_config"
+)
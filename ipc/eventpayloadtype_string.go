@@ -9,6 +9,7 @@ func _() {
 	// Re-run the stringer command to generate them again.
 	var x [1]struct{}
 	_ = x[WorkspaceEvent-2147483648]
+	_ = x[OutputEvent-2147483649]
 	_ = x[ModeEvent-2147483650]
 	_ = x[WindowEvent-2147483651]
 	_ = x[BarconfigUpdateEvent-2147483652]
@@ -20,26 +21,23 @@ func _() {
 }
 
 const (
-	_EventPayloadType_name_0 = "WorkspaceEvent"
-	_EventPayloadType_name_1 = "ModeEventWindowEventBarconfigUpdateEventBindingEventShutdownEventTickEvent"
-	_EventPayloadType_name_2 = "BarStateUpdateEventInputEvent"
+	_EventPayloadType_name_0 = "WorkspaceEventOutputEventModeEventWindowEventBarconfigUpdateEventBindingEventShutdownEventTickEvent"
+	_EventPayloadType_name_1 = "BarStateUpdateEventInputEvent"
 )
 
 var (
-	_EventPayloadType_index_1 = [...]uint8{0, 9, 20, 40, 52, 65, 74}
-	_EventPayloadType_index_2 = [...]uint8{0, 19, 29}
+	_EventPayloadType_index_0 = [...]uint8{0, 14, 25, 34, 45, 65, 77, 90, 99}
+	_EventPayloadType_index_1 = [...]uint8{0, 19, 29}
 )
 
 func (i EventPayloadType) String() string {
 	switch {
-	case i == 2147483648:
-		return _EventPayloadType_name_0
-	case 2147483650 <= i && i <= 2147483655:
-		i -= 2147483650
-		return _EventPayloadType_name_1[_EventPayloadType_index_1[i]:_EventPayloadType_index_1[i+1]]
+	case 2147483648 <= i && i <= 2147483655:
+		i -= 2147483648
+		return _EventPayloadType_name_0[_EventPayloadType_index_0[i]:_EventPayloadType_index_0[i+1]]
 	case 2147483668 <= i && i <= 2147483669:
 		i -= 2147483668
-		return _EventPayloadType_name_2[_EventPayloadType_index_2[i]:_EventPayloadType_index_2[i+1]]
+		return _EventPayloadType_name_1[_EventPayloadType_index_1[i]:_EventPayloadType_index_1[i+1]]
 	default:
 		return "EventPayloadType(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
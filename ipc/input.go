@@ -0,0 +1,53 @@
+package ipc
+
+type Input struct {
+	Identifier           string          `json:"identifier"`
+	Name                 string          `json:"name"`
+	Vendor               int             `json:"vendor"`
+	Product              int             `json:"product"`
+	Type                 InputDeviceType `json:"type"`
+	XkbActiveLayoutName  *string         `json:"xkb_active_layout_name,omitempty"`
+	XkbLayoutNames       []string        `json:"xkb_layout_names,omitempty"`
+	XkbActiveLayoutIndex *int            `json:"xkb_active_layout_index,omitempty"`
+	ScrollFactor         *float64        `json:"scroll_factor,omitempty"`
+	Libinput             *Libinput       `json:"libinput,omitempty"`
+}
+
+type InputDeviceType string
+
+const (
+	KeyboardInputDevice   InputDeviceType = "keyboard"
+	PointerInputDevice    InputDeviceType = "pointer"
+	TouchInputDevice      InputDeviceType = "touch"
+	TabletToolInputDevice InputDeviceType = "tablet_tool"
+	TabletPadInputDevice  InputDeviceType = "tablet_pad"
+	SwitchInputDevice     InputDeviceType = "switch"
+)
+
+// Libinput holds the libinput settings of an Input.
+// Only the settings supported by the device are set.
+type Libinput struct {
+	SendEvents        string      `json:"send_events,omitempty"`
+	Tap               string      `json:"tap,omitempty"`
+	TapButtonMap      string      `json:"tap_button_map,omitempty"`
+	TapDrag           string      `json:"tap_drag,omitempty"`
+	TapDragLock       string      `json:"tap_drag_lock,omitempty"`
+	AccelSpeed        *float64    `json:"accel_speed,omitempty"`
+	AccelProfile      string      `json:"accel_profile,omitempty"`
+	NaturalScroll     string      `json:"natural_scroll,omitempty"`
+	LeftHanded        string      `json:"left_handed,omitempty"`
+	ClickMethod       string      `json:"click_method,omitempty"`
+	MiddleEmulation   string      `json:"middle_emulation,omitempty"`
+	ScrollMethod      string      `json:"scroll_method,omitempty"`
+	ScrollButton      *int        `json:"scroll_button,omitempty"`
+	Dwt               string      `json:"dwt,omitempty"`
+	Dwtp              string      `json:"dwtp,omitempty"`
+	CalibrationMatrix *[6]float64 `json:"calibration_matrix,omitempty"`
+}
+
+type Seat struct {
+	Name         string  `json:"name"`
+	Capabilities int     `json:"capabilities"`
+	Focus        int     `json:"focus"`
+	Devices      []Input `json:"devices"`
+}
@@ -1,5 +1,7 @@
 package ipc
 
+import "sync"
+
 //go:generate go run golang.org/x/tools/cmd/stringer -type=PayloadType
 type PayloadType uint32
 
@@ -26,6 +28,7 @@ type EventPayloadType PayloadType
 
 const (
 	WorkspaceEvent       EventPayloadType = 0x80000000
+	OutputEvent          EventPayloadType = 0x80000001
 	ModeEvent            EventPayloadType = 0x80000002
 	WindowEvent          EventPayloadType = 0x80000003
 	BarconfigUpdateEvent EventPayloadType = 0x80000004
@@ -54,10 +57,13 @@ func NewHeader(pt PayloadType, plen int) *Header {
 	return &Header{Magic: magic, PayloadLength: uint32(plen), PayloadType: pt}
 }
 
-func (p EventPayloadType) eventName() string {
+// EventName returns the name of the event in SUBSCRIBE payloads.
+func (p EventPayloadType) EventName() string {
 	switch p {
 	case WorkspaceEvent:
 		return "workspace"
+	case OutputEvent:
+		return "output"
 	case ModeEvent:
 		return "mode"
 	case WindowEvent:
@@ -71,18 +77,33 @@ func (p EventPayloadType) eventName() string {
 	case TickEvent:
 		return "tick"
 	case BarStateUpdateEvent:
-		return "bar_status_update"
+		return "bar_state_update"
 	case InputEvent:
 		return "input"
 	}
 
-	return ""
+	eventNamesmx.Lock()
+	defer eventNamesmx.Unlock()
+	return extraEventNames[p]
+}
+
+var (
+	eventNamesmx    sync.Mutex
+	extraEventNames = map[EventPayloadType]string{}
+)
+
+// RegisterEventName names an event type this package does not know,
+// so it can be subscribed to with RawEvents or On.
+func RegisterEventName(p EventPayloadType, name string) {
+	eventNamesmx.Lock()
+	defer eventNamesmx.Unlock()
+	extraEventNames[p] = name
 }
 
 func eventNames(ps []EventPayloadType) []string {
 	s := make([]string, len(ps))
 	for i, p := range ps {
-		s[i] = p.eventName()
+		s[i] = p.EventName()
 	}
 
 	return s
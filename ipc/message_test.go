@@ -16,8 +16,10 @@ func TestEventPayloadType(t *testing.T) {
 		ipc.WorkspaceEvent,
 		ipc.WindowEvent,
 		ipc.TickEvent,
+		ipc.OutputEvent,
 	} {
 		assert.NotEmpty(t, ept.String())
+		assert.NotContains(t, ept.String(), "EventPayloadType(")
 	}
 }
 
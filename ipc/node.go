@@ -28,6 +28,20 @@ type Node struct {
 	Shell              *string             `json:"shell"`
 	Window             *int                `json:"window"`
 	WindowProperties   *WindowProperties   `json:"window_properties"`
+	// Num is the number of a workspace, -1 for workspaces whose
+	// name does not start with a number, and nil for other containers.
+	Num *int `json:"num"`
+	// Output is the name of the output of a workspace.
+	Output *string `json:"output"`
+	// The fields below are sent for views, MaxRenderTime for outputs too.
+	InhibitIdle               *bool           `json:"inhibit_idle"`
+	IdleInhibitors            *IdleInhibitors `json:"idle_inhibitors"`
+	MaxRenderTime             *int            `json:"max_render_time"`
+	ForeignToplevelIdentifier *string         `json:"foreign_toplevel_identifier"`
+	SandboxEngine             *string         `json:"sandbox_engine"`
+	SandboxAppID              *string         `json:"sandbox_app_id"`
+	SandboxInstanceID         *string         `json:"sandbox_instance_id"`
+	Tag                       *string         `json:"tag"`
 }
 
 type NodeType string
@@ -76,8 +90,55 @@ const (
 	GlobalFullscreenMode    FullscreenModeType = 2
 )
 
+// WindowProperties are the X11 properties of an xwayland view.
+// Sway leaves out the properties the window does not set.
 type WindowProperties struct {
-	Class        string      `json:"class"`
-	Instance     string      `json:"instance"`
-	TransientFor interface{} `json:"transient_for"`
+	Class        string      `json:"class,omitempty"`
+	Instance     string      `json:"instance,omitempty"`
+	Title        *string     `json:"title,omitempty"`
+	WindowRole   *string     `json:"window_role,omitempty"`
+	WindowType   *WindowType `json:"window_type,omitempty"`
+	TransientFor *int        `json:"transient_for"`
 }
+
+type WindowType string
+
+const (
+	NormalWindowType       WindowType = "normal"
+	DialogWindowType       WindowType = "dialog"
+	UtilityWindowType      WindowType = "utility"
+	ToolbarWindowType      WindowType = "toolbar"
+	SplashWindowType       WindowType = "splash"
+	MenuWindowType         WindowType = "menu"
+	DropdownMenuWindowType WindowType = "dropdown_menu"
+	PopupMenuWindowType    WindowType = "popup_menu"
+	TooltipWindowType      WindowType = "tooltip"
+	NotificationWindowType WindowType = "notification"
+	UnknownWindowType      WindowType = "unknown"
+)
+
+// IdleInhibitors tells what keeps a view from letting the system idle.
+type IdleInhibitors struct {
+	User        UserIdleInhibitor        `json:"user"`
+	Application ApplicationIdleInhibitor `json:"application"`
+}
+
+// UserIdleInhibitor is set with the inhibit_idle command.
+type UserIdleInhibitor string
+
+const (
+	FocusUserIdleInhibitor      UserIdleInhibitor = "focus"
+	FullscreenUserIdleInhibitor UserIdleInhibitor = "fullscreen"
+	OpenUserIdleInhibitor       UserIdleInhibitor = "open"
+	VisibleUserIdleInhibitor    UserIdleInhibitor = "visible"
+	NoneUserIdleInhibitor       UserIdleInhibitor = "none"
+)
+
+// ApplicationIdleInhibitor is set by the view through the
+// idle-inhibit protocol.
+type ApplicationIdleInhibitor string
+
+const (
+	EnabledApplicationIdleInhibitor ApplicationIdleInhibitor = "enabled"
+	NoneApplicationIdleInhibitor    ApplicationIdleInhibitor = "none"
+)
@@ -0,0 +1,140 @@
+package ipc_test
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/libanvl/swager/ipc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outputOnly are the GET_OUTPUTS fields sway adds to output nodes,
+// which Node does not model.
+var outputOnly = []string{
+	"primary", "make", "model", "serial", "modes", "non_desktop", "active",
+	"dpms", "power", "scale", "scale_filter", "transform", "adaptive_sync_status",
+	"current_workspace", "current_mode", "allow_tearing", "subpixel_hinting",
+}
+
+func loadTree(t *testing.T) []byte {
+	buf, err := os.ReadFile("testdata/get_tree.json")
+	require.Nil(t, err)
+	return buf
+}
+
+func TestNodeRoundTrip(t *testing.T) {
+	buf := loadTree(t)
+
+	root := new(ipc.Node)
+	require.Nil(t, json.Unmarshal(buf, root))
+
+	encoded, err := json.Marshal(root)
+	require.Nil(t, err)
+
+	again := new(ipc.Node)
+	require.Nil(t, json.Unmarshal(encoded, again))
+	assert.Equal(t, root, again)
+
+	var sample, actual map[string]any
+	require.Nil(t, json.Unmarshal(buf, &sample))
+	require.Nil(t, json.Unmarshal(encoded, &actual))
+	compareNodes(t, "root", sample, actual)
+}
+
+// compareNodes checks that every field sway sent for a node survived
+// the round trip, and that no field sway left out came back but null.
+func compareNodes(t *testing.T, path string, sample map[string]any, actual map[string]any) {
+	for key, value := range actual {
+		if _, ok := sample[key]; !ok {
+			assert.Nil(t, value, path+"."+key)
+		}
+	}
+
+	for key, value := range sample {
+		switch key {
+		case "nodes", "floating_nodes":
+			children := value.([]any)
+			encoded, _ := actual[key].([]any)
+			require.Len(t, encoded, len(children), path+"."+key)
+			for i := range children {
+				compareNodes(t, path+"."+key+"."+strconv.Itoa(i),
+					children[i].(map[string]any), encoded[i].(map[string]any))
+			}
+		default:
+			if sample["type"] == "output" && contains(outputOnly, key) {
+				continue
+			}
+
+			assert.Contains(t, actual, key, path)
+			assert.Equal(t, value, actual[key], path+"."+key)
+		}
+	}
+}
+
+func contains(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestNodeFields(t *testing.T) {
+	root := new(ipc.Node)
+	require.Nil(t, json.Unmarshal(loadTree(t), root))
+
+	scratch := root.Nodes[0].Nodes[0]
+	assert.Equal(t, -1, *scratch.Num)
+	assert.Equal(t, "__i3", *scratch.Output)
+
+	ws := root.Nodes[1].Nodes[0]
+	assert.Equal(t, 1, *ws.Num)
+	assert.Equal(t, "eDP-1", *ws.Output)
+	assert.Nil(t, ws.InhibitIdle)
+	assert.Nil(t, ws.IdleInhibitors)
+
+	foot := ws.Nodes[0]
+	assert.Nil(t, foot.Num)
+	assert.Equal(t, false, *foot.InhibitIdle)
+	assert.Equal(t, ipc.IdleInhibitors{
+		User:        ipc.NoneUserIdleInhibitor,
+		Application: ipc.NoneApplicationIdleInhibitor,
+	}, *foot.IdleInhibitors)
+	assert.Equal(t, 0, *foot.MaxRenderTime)
+	assert.Equal(t, "d1b2f6a8e1c64a7fa3c1e0b5a9f4c2d7", *foot.ForeignToplevelIdentifier)
+	assert.Nil(t, foot.SandboxEngine)
+	assert.Nil(t, foot.Tag)
+	assert.Nil(t, foot.WindowProperties)
+
+	player := ws.Nodes[1]
+	assert.Equal(t, true, *player.InhibitIdle)
+	assert.Equal(t, ipc.FullscreenUserIdleInhibitor, player.IdleInhibitors.User)
+	assert.Equal(t, ipc.EnabledApplicationIdleInhibitor, player.IdleInhibitors.Application)
+	assert.Equal(t, 7, *player.MaxRenderTime)
+	assert.Equal(t, "org.flatpak", *player.SandboxEngine)
+	assert.Equal(t, "org.example.Player", *player.SandboxAppID)
+	assert.Equal(t, "1734567890", *player.SandboxInstanceID)
+	assert.Equal(t, "player", *player.Tag)
+
+	transient := 4194306
+	title, role, typ := "Save changes?", "gimp-query-box", ipc.DialogWindowType
+	dialog := ws.FloatingNodes[0]
+	assert.Nil(t, dialog.AppID)
+	assert.Equal(t, 4194313, *dialog.Window)
+	assert.Equal(t, &ipc.WindowProperties{
+		Class:        "Gimp",
+		Instance:     "gimp",
+		Title:        &title,
+		WindowRole:   &role,
+		WindowType:   &typ,
+		TransientFor: &transient,
+	}, dialog.WindowProperties)
+
+	xterm := ws.FloatingNodes[1]
+	assert.Equal(t, &ipc.WindowProperties{Class: "XTerm", Instance: "xterm"}, xterm.WindowProperties)
+}
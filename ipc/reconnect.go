@@ -0,0 +1,159 @@
+package ipc
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Backoff configures how a reconnecting Client or Subscription
+// redials the sway-ipc socket. The delay between attempts starts at Min
+// and doubles up to Max. Attempts limits the number of dials per
+// reconnect; zero means no limit.
+type Backoff struct {
+	Min      time.Duration
+	Max      time.Duration
+	Attempts int
+}
+
+// DefaultBackoff retries forever, waiting at most five seconds
+// between attempts.
+var DefaultBackoff = Backoff{Min: 100 * time.Millisecond, Max: 5 * time.Second}
+
+func (b Backoff) delay(attempt int) time.Duration {
+	d := b.Min
+	for i := 0; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+
+	if d > b.Max {
+		d = b.Max
+	}
+
+	return d
+}
+
+// SetReconnect puts the Client in reconnecting mode.
+// After the connection fails, the next call redials the socket
+// using the given Backoff. Calls are never retried, so the call that
+// observed the failure still returns its error.
+// Only Clients created with Connect or ConnectCustom can reconnect.
+func (c *Client) SetReconnect(b Backoff) {
+	c.backoff = &b
+}
+
+// redial replaces the connection of the Client, retrying with
+// the configured Backoff until it succeeds, the attempts run out
+// or ctx is done. It returns the number of dials made.
+func (c *Client) redial(ctx context.Context) (int, error) {
+	if c.dial == nil {
+		return 0, ErrConnectionReset
+	}
+
+	b := Backoff{Attempts: 1}
+	if c.backoff != nil {
+		b = *c.backoff
+	}
+
+	var err error
+	for attempt := 0; b.Attempts == 0 || attempt < b.Attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return attempt, ctx.Err()
+			case <-time.After(b.delay(attempt - 1)):
+			}
+		}
+
+		var conn io.ReadWriteCloser
+		conn, err = c.dialConn()
+		if err == nil {
+			c.ReadWriteCloser = conn
+			c.broken = false
+			return attempt + 1, nil
+		}
+	}
+
+	return b.Attempts, err
+}
+
+// dialConn dials a new connection to sway,
+// wrapped like the current one.
+func (c *Client) dialConn() (io.ReadWriteCloser, error) {
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.wrap != nil {
+		conn = c.wrap(conn)
+	}
+
+	return conn, nil
+}
+
+// Reconnected is delivered to Reconnects handlers after a reconnecting
+// Subscription has redialed sway and subscribed to its events again.
+// Events sent while the Subscription was disconnected are lost.
+type Reconnected struct {
+	// Attempts is the number of dials it took to reconnect.
+	Attempts int
+	// Downtime is how long the Subscription was disconnected.
+	Downtime time.Duration
+}
+
+// SetReconnect puts the Subscription in reconnecting mode.
+// When reading an event fails, Run redials the socket using the
+// given Backoff, subscribes again to every event type that has handlers
+// and notifies the Reconnects handlers. Registered cookies stay valid.
+func (s *Subscription) SetReconnect(b Backoff) {
+	if s.client != nil {
+		s.client.SetReconnect(b)
+	}
+}
+
+// Reconnects registers a new handler that is called each time
+// a reconnecting Subscription has reconnected.
+func (s *Subscription) Reconnects(h func(Reconnected)) (Cookie, error) {
+	cookie, _, err := addHandler(s, &s.reconnects, nil, h)
+	return cookie, err
+}
+
+// reconnect redials the socket of the Subscription and restores
+// its event subscriptions.
+func (s *Subscription) reconnect(ctx context.Context) error {
+	start := time.Now()
+	s.client.reset()
+
+	attempts, err := s.client.redial(ctx)
+	if err != nil {
+		return err
+	}
+
+	evts := s.subscribedEvents()
+	if len(evts) > 0 {
+		res, err := s.client.SubscribeCtx(ctx, evts...)
+		if err != nil {
+			return err
+		}
+
+		if !res.Success {
+			return errSubscribeFailed
+		}
+	}
+
+	s.conngen++
+	s.subscribed = make(map[EventPayloadType]bool, len(evts))
+	for _, t := range evts {
+		s.subscribed[t] = true
+	}
+
+	// wake a subscribe call waiting for a reply lost with the old connection
+	select {
+	case s.redialed <- struct{}{}:
+	default:
+	}
+
+	dispatch(s, &s.reconnects, Reconnected{attempts, time.Since(start)})
+	return nil
+}
@@ -0,0 +1,31 @@
+/*
+Package record records sway-ipc sessions and replays them.
+
+A Recorder wraps connections to sway and writes every request, reply and
+event that passes through them to a JSONL stream, one Entry per line,
+with a timestamp. Each wrapped connection is a session, so the Client and
+the Subscription of a program can share one recording.
+
+A Replay serves a recording back. Each session becomes a fake connection
+that answers the requests with the recorded replies and delivers the
+recorded events. Entries are released strictly in recorded order across
+all sessions: an event recorded after a request is only delivered once
+that request was sent. Concurrent handlers then see the same sequence as
+during the recording, so a layout bug captured on one machine can be
+reproduced in a test.
+
+Example
+
+	f, _ := os.Create("session.jsonl")
+	rec := record.NewRecorder(f, binary.LittleEndian)
+	client.WrapConn(rec.Wrap)
+	sub.WrapConn(rec.Wrap)
+
+and later, in a test
+
+	entries, _ := record.Load(f)
+	replay := record.NewReplay(entries, binary.LittleEndian)
+	client := ipc.NewClient(replay.Conn(1), binary.LittleEndian)
+	sub := ipc.SubscribeCustom(ipc.NewClient(replay.Conn(2), binary.LittleEndian))
+*/
+package record
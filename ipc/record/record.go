@@ -0,0 +1,217 @@
+package record
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/libanvl/swager/ipc"
+)
+
+// headerSize is the size of an encoded ipc.Header.
+const headerSize = 14
+
+// Direction tells who sent the message of an Entry.
+type Direction string
+
+const (
+	// Request is a message sent to sway.
+	Request Direction = "request"
+	// Reply is the reply of sway to a Request.
+	Reply Direction = "reply"
+	// Event is an event sent by sway to a subscribed session.
+	Event Direction = "event"
+)
+
+// Entry is a single message of a recording.
+type Entry struct {
+	Time      time.Time       `json:"time"`
+	Session   int             `json:"session"`
+	Direction Direction       `json:"dir"`
+	Type      ipc.PayloadType `json:"type"`
+	// Name is the message or event name, for people reading the recording.
+	Name    string `json:"name,omitempty"`
+	Payload string `json:"payload"`
+}
+
+// Load reads a recording written by a Recorder.
+func Load(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64*1024*1024)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Recorder writes the messages of the connections it wraps as JSONL.
+type Recorder struct {
+	mx       sync.Mutex
+	enc      *json.Encoder
+	yo       binary.ByteOrder
+	sessions int
+	err      error
+	now      func() time.Time
+}
+
+// NewRecorder returns a Recorder writing to w.
+// yo is the byte order of the wrapped connections.
+func NewRecorder(w io.Writer, yo binary.ByteOrder) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w), yo: yo, now: time.Now}
+}
+
+// Wrap returns a connection that records the messages passing through
+// conn as a new session. Its signature fits ipc.Client.WrapConn.
+func (r *Recorder) Wrap(conn io.ReadWriteCloser) io.ReadWriteCloser {
+	r.mx.Lock()
+	r.sessions++
+	rc := &recordingConn{ReadWriteCloser: conn, r: r, session: r.sessions}
+	r.mx.Unlock()
+
+	if d, ok := conn.(deadliner); ok {
+		return &deadlineRecordingConn{rc, d}
+	}
+
+	return rc
+}
+
+// Err returns the first error writing the recording.
+// Failing to record never fails the wrapped connections.
+func (r *Recorder) Err() error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.err
+}
+
+func (r *Recorder) record(session int, dir Direction, h *ipc.Header, payload []byte) {
+	e := Entry{
+		Session:   session,
+		Direction: dir,
+		Type:      h.PayloadType,
+		Name:      name(dir, h.PayloadType),
+		Payload:   string(payload),
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	e.Time = r.now()
+	if err := r.enc.Encode(e); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+type deadliner interface {
+	SetDeadline(t time.Time) error
+}
+
+type recordingConn struct {
+	io.ReadWriteCloser
+	r       *Recorder
+	session int
+	wmx     sync.Mutex
+	wbuf    []byte
+	rmx     sync.Mutex
+	rbuf    []byte
+}
+
+type deadlineRecordingConn struct {
+	*recordingConn
+	d deadliner
+}
+
+func (c *deadlineRecordingConn) SetDeadline(t time.Time) error {
+	return c.d.SetDeadline(t)
+}
+
+func (c *recordingConn) Write(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Write(p)
+
+	c.wmx.Lock()
+	defer c.wmx.Unlock()
+	c.wbuf = c.frames(append(c.wbuf, p[:n]...), func(h *ipc.Header, payload []byte) {
+		c.r.record(c.session, Request, h, payload)
+	})
+
+	return n, err
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Read(p)
+
+	c.rmx.Lock()
+	defer c.rmx.Unlock()
+	c.rbuf = c.frames(append(c.rbuf, p[:n]...), func(h *ipc.Header, payload []byte) {
+		dir := Reply
+		if isEvent(h.PayloadType) {
+			dir = Event
+		}
+		c.r.record(c.session, dir, h, payload)
+	})
+
+	return n, err
+}
+
+// frames calls f for each complete message at the start of buf
+// and returns what remains.
+func (c *recordingConn) frames(buf []byte, f func(*ipc.Header, []byte)) []byte {
+	for {
+		h, payload, rest, ok := frame(buf, c.r.yo)
+		if !ok {
+			return buf
+		}
+
+		f(h, payload)
+		buf = rest
+	}
+}
+
+// frame decodes the message at the start of buf.
+func frame(buf []byte, yo binary.ByteOrder) (*ipc.Header, []byte, []byte, bool) {
+	if len(buf) < headerSize {
+		return nil, nil, buf, false
+	}
+
+	h := new(ipc.Header)
+	copy(h.Magic[:], buf[:6])
+	h.PayloadLength = yo.Uint32(buf[6:10])
+	h.PayloadType = ipc.PayloadType(yo.Uint32(buf[10:14]))
+
+	end := headerSize + int(h.PayloadLength)
+	if len(buf) < end {
+		return nil, nil, buf, false
+	}
+
+	return h, buf[headerSize:end], buf[end:], true
+}
+
+func isEvent(pt ipc.PayloadType) bool {
+	return pt&0x80000000 != 0
+}
+
+func name(dir Direction, pt ipc.PayloadType) string {
+	if dir == Event {
+		return ipc.EventPayloadType(pt).EventName()
+	}
+
+	return pt.String()
+}
@@ -0,0 +1,133 @@
+package record_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libanvl/swager/ipc"
+	"github.com/libanvl/swager/ipc/record"
+	"github.com/libanvl/swager/test/swaytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordSession records a command on a Client and a window event
+// on a Subscription.
+func recordSession(t *testing.T) []byte {
+	server := swaytest.NewServer(t)
+	server.Reply(ipc.GetWorkspacesMessage, []ipc.Workspace{{Num: 1, Name: "1", Focused: true}})
+
+	var buf bytes.Buffer
+	rec := record.NewRecorder(&buf, binary.LittleEndian)
+
+	client, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+	require.Nil(t, err)
+	client.WrapConn(rec.Wrap)
+
+	sc, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+	require.Nil(t, err)
+	sub := ipc.SubscribeCustom(sc)
+	sub.WrapConn(rec.Wrap)
+
+	windows := make(chan ipc.WindowChange, 1)
+	_, err = sub.WindowChanges(func(wc ipc.WindowChange) { windows <- wc })
+	require.Nil(t, err)
+	go sub.Run()
+
+	server.Emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.NewWindow, Container: ipc.Node{ID: 7}})
+	<-windows
+
+	_, err = client.Workspaces()
+	require.Nil(t, err)
+	require.Nil(t, client.CommandChecked("[con_id=7] focus"))
+
+	sub.Close()
+	client.Close()
+	require.Nil(t, rec.Err())
+
+	return buf.Bytes()
+}
+
+func TestRecorder(t *testing.T) {
+	entries, err := record.Load(bytes.NewReader(recordSession(t)))
+	require.Nil(t, err)
+	require.Len(t, entries, 7)
+
+	type brief struct {
+		Session   int
+		Direction record.Direction
+		Name      string
+	}
+
+	var got []brief
+	for _, e := range entries {
+		assert.False(t, e.Time.IsZero())
+		got = append(got, brief{e.Session, e.Direction, e.Name})
+	}
+
+	assert.Equal(t, []brief{
+		{2, record.Request, "subscribeMessage"},
+		{2, record.Reply, "subscribeMessage"},
+		{2, record.Event, "window"},
+		{1, record.Request, "getWorkspacesMessage"},
+		{1, record.Reply, "getWorkspacesMessage"},
+		{1, record.Request, "runCommandMessage"},
+		{1, record.Reply, "runCommandMessage"},
+	}, got)
+
+	assert.Equal(t, `["window"]`, entries[0].Payload)
+	assert.Equal(t, "[con_id=7] focus", entries[5].Payload)
+}
+
+func TestReplay(t *testing.T) {
+	entries, err := record.Load(bytes.NewReader(recordSession(t)))
+	require.Nil(t, err)
+
+	replay := record.NewReplay(entries, binary.BigEndian)
+	client := ipc.NewClient(replay.Conn(1), binary.BigEndian)
+	sub := ipc.SubscribeCustom(ipc.NewClient(replay.Conn(2), binary.BigEndian))
+
+	// the handler runs the same requests as during the recording
+	errs := make(chan error, 1)
+	_, err = sub.WindowChanges(func(wc ipc.WindowChange) {
+		wss, err := client.Workspaces()
+		if err == nil && (len(wss) != 1 || wss[0].Name != "1") {
+			err = errors.New("unexpected workspaces")
+		}
+		if err == nil {
+			err = client.CommandChecked("[con_id=7] focus")
+		}
+		errs <- err
+	})
+	require.Nil(t, err)
+	go sub.Run()
+
+	assert.Nil(t, <-errs)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, replay.Wait(ctx))
+	assert.True(t, replay.Done())
+}
+
+func TestReplayMismatch(t *testing.T) {
+	entries, err := record.Load(bytes.NewReader(recordSession(t)))
+	require.Nil(t, err)
+
+	replay := record.NewReplay(entries, binary.LittleEndian)
+	defer replay.Close()
+	client := ipc.NewClient(replay.Conn(1), binary.LittleEndian)
+	replay.Conn(2).Close()
+
+	_, err = client.Tree()
+	var mismatch *record.MismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, 3, mismatch.Index)
+	assert.Equal(t, ipc.GetWorkspacesMessage, mismatch.Expected.Type)
+	assert.Equal(t, ipc.GetTreeMessage, mismatch.Got.Type)
+	assert.ErrorAs(t, replay.Err(), &mismatch)
+}
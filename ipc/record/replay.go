@@ -0,0 +1,259 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/libanvl/swager/ipc"
+)
+
+// ErrReplayClosed is returned by the connections of a closed Replay.
+var ErrReplayClosed = errors.New("replay closed")
+
+// MismatchError is returned when a request sent to a Replay connection
+// is not the next request of the recording.
+type MismatchError struct {
+	// Index is the position of the expected Entry in the recording.
+	Index    int
+	Expected Entry
+	Got      Entry
+}
+
+func (e *MismatchError) Error() string {
+	if e.Expected.Direction == "" {
+		return fmt.Sprintf("replay entry %d: unexpected %s %q in session %d after the end of the recording",
+			e.Index, e.Got.Name, e.Got.Payload, e.Got.Session)
+	}
+
+	return fmt.Sprintf("replay entry %d: expected %s %q in session %d, got %s %q in session %d",
+		e.Index, e.Expected.Name, e.Expected.Payload, e.Expected.Session,
+		e.Got.Name, e.Got.Payload, e.Got.Session)
+}
+
+// Replay serves a recording through fake connections, one per session.
+type Replay struct {
+	mx      sync.Mutex
+	cond    *sync.Cond
+	yo      binary.ByteOrder
+	entries []Entry
+	next    int
+	conns   map[int]*replayConn
+	err     error
+	closed  bool
+}
+
+// NewReplay returns a Replay of entries, encoding messages
+// with the byte order yo.
+func NewReplay(entries []Entry, yo binary.ByteOrder) *Replay {
+	r := &Replay{yo: yo, entries: entries, conns: make(map[int]*replayConn)}
+	r.cond = sync.NewCond(&r.mx)
+	return r
+}
+
+// Conn returns the fake connection of a session.
+// Replies and events of the session are held until it is opened.
+func (r *Replay) Conn(session int) io.ReadWriteCloser {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	c, ok := r.conns[session]
+	if !ok {
+		c = &replayConn{r: r, session: session}
+		r.conns[session] = c
+		r.advance()
+	}
+
+	return c
+}
+
+// Done reports whether every entry of the recording was replayed.
+func (r *Replay) Done() bool {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.next >= len(r.entries)
+}
+
+// Err returns the first *MismatchError of the Replay, if any.
+func (r *Replay) Err() error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.err
+}
+
+// Wait blocks until every entry was replayed, a request did not
+// match the recording or ctx is done.
+func (r *Replay) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.mx.Lock()
+			r.cond.Broadcast()
+			r.mx.Unlock()
+		case <-done:
+		}
+	}()
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	for r.next < len(r.entries) && r.err == nil && !r.closed {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		r.cond.Wait()
+	}
+
+	if r.closed && r.next < len(r.entries) {
+		return ErrReplayClosed
+	}
+
+	return r.err
+}
+
+// Close closes every connection of the Replay.
+func (r *Replay) Close() error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.closed = true
+	r.cond.Broadcast()
+	return nil
+}
+
+// advance releases the replies and events at the head of the recording
+// to their sessions, up to the next request. Must hold r.mx.
+func (r *Replay) advance() {
+	for ; r.next < len(r.entries); r.next++ {
+		e := r.entries[r.next]
+		c, ok := r.conns[e.Session]
+		if c != nil && c.closed {
+			// the session ended, nobody is left to send or receive
+			continue
+		}
+
+		if e.Direction == Request || !ok {
+			break
+		}
+
+		h := ipc.NewHeader(e.Type, len(e.Payload))
+		binary.Write(&c.rbuf, r.yo, h)
+		c.rbuf.WriteString(e.Payload)
+	}
+
+	r.cond.Broadcast()
+}
+
+// remaining reports whether the recording holds more entries for session.
+// Must hold r.mx.
+func (r *Replay) remaining(session int) bool {
+	for _, e := range r.entries[r.next:] {
+		if e.Session == session {
+			return true
+		}
+	}
+
+	return false
+}
+
+type replayConn struct {
+	r       *Replay
+	session int
+	rbuf    bytes.Buffer
+	wbuf    []byte
+	closed  bool
+}
+
+func (c *replayConn) Read(p []byte) (int, error) {
+	r := c.r
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	for c.rbuf.Len() == 0 {
+		switch {
+		case c.closed || r.closed:
+			return 0, ErrReplayClosed
+		case r.err != nil:
+			return 0, r.err
+		case !r.remaining(c.session):
+			return 0, io.EOF
+		}
+		r.cond.Wait()
+	}
+
+	return c.rbuf.Read(p)
+}
+
+func (c *replayConn) Write(p []byte) (int, error) {
+	r := c.r
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if c.closed || r.closed {
+		return 0, ErrReplayClosed
+	}
+
+	c.wbuf = append(c.wbuf, p...)
+	for {
+		h, payload, rest, ok := frame(c.wbuf, r.yo)
+		if !ok {
+			return len(p), nil
+		}
+
+		c.wbuf = rest
+		if err := c.request(h, payload); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// request waits until the next entry is a request of the session
+// and checks that it matches. Must hold r.mx.
+func (c *replayConn) request(h *ipc.Header, payload []byte) error {
+	r := c.r
+	got := Entry{Session: c.session, Direction: Request, Type: h.PayloadType,
+		Name: name(Request, h.PayloadType), Payload: string(payload)}
+
+	for {
+		switch {
+		case c.closed || r.closed:
+			return ErrReplayClosed
+		case r.err != nil:
+			return r.err
+		case r.next >= len(r.entries):
+			r.err = &MismatchError{Index: r.next, Got: got}
+			r.cond.Broadcast()
+			return r.err
+		}
+
+		e := r.entries[r.next]
+		if e.Session == c.session && e.Direction == Request {
+			if e.Type != got.Type || e.Payload != got.Payload {
+				r.err = &MismatchError{Index: r.next, Expected: e, Got: got}
+				r.cond.Broadcast()
+				return r.err
+			}
+
+			r.next++
+			r.advance()
+			return nil
+		}
+
+		r.cond.Wait()
+	}
+}
+
+func (c *replayConn) Close() error {
+	r := c.r
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	c.closed = true
+	r.advance()
+	return nil
+}
@@ -8,6 +8,10 @@ type Version struct {
 	LoadedConfigFileName string `json:"loaded_config_file_name"`
 }
 
+type Config struct {
+	Config string `json:"config"`
+}
+
 type Result struct {
 	Success bool `json:"success"`
 }
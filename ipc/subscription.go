@@ -1,26 +1,56 @@
 package ipc
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Subscription wraps a Client. It registers event handlers
 // for the supported sway-ipc events.
 type Subscription struct {
+	// seq is accessed atomically, it comes first to be 64-bit aligned
+	seq        uint64
 	client     *Client
 	errors     []chan<- error
+	errorsmx   sync.Mutex
 	clientmx   sync.Mutex
+	conngen    uint32
+	subscribed map[EventPayloadType]bool
+	submx      sync.Mutex
+	running    bool
+	replies    chan []byte
+	redialed   chan struct{}
+	stopped    chan struct{}
+	early      []message
 	currcookie uint32
 	workspaces mapSyncPair[WorkspaceChange]
+	outputs    mapSyncPair[OutputChange]
 	modes      mapSyncPair[ModeChange]
 	windows    mapSyncPair[WindowChange]
+	barconfigs mapSyncPair[BarConfigUpdate]
 	bindings   mapSyncPair[BindingChange]
 	shutdowns  mapSyncPair[ShutdownChange]
 	ticks      mapSyncPair[Tick]
+	barstates  mapSyncPair[BarStateUpdate]
+	inputs     mapSyncPair[InputChange]
+	reconnects mapSyncPair[Reconnected]
+	raws       mapSyncPair[RawEvent]
+	streams    map[Cookie]*stream
+	streamsmx  sync.Mutex
+	delivery   Delivery
+	inflight   tracker
+	drain      time.Duration
+	closed     int32
+	ctx        context.Context
+	cancel     context.CancelFunc
 }
 
 // Cookie represents a single registered event handler.
@@ -50,6 +80,11 @@ func SubscribeCustom(client *Client) *Subscription {
 	s := new(Subscription)
 	s.client = client
 	s.errors = make([]chan<- error, 0)
+	s.subscribed = make(map[EventPayloadType]bool)
+	s.replies = make(chan []byte, 1)
+	s.redialed = make(chan struct{}, 1)
+	s.drain = DefaultDrainTimeout
+	s.ctx, s.cancel = context.WithCancel(context.Background())
 
 	return s
 }
@@ -57,14 +92,37 @@ func SubscribeCustom(client *Client) *Subscription {
 // Errors returns the channel that subscription errors are yielded on.
 // All errors from this channel are of type MonitoringError.
 func (s *Subscription) Errors(ch chan<- error) {
+	s.errorsmx.Lock()
+	defer s.errorsmx.Unlock()
 	s.errors = append(s.errors, ch)
 }
 
+// WrapConn wraps the connection of the Subscription,
+// like Client.WrapConn.
+func (s *Subscription) WrapConn(w func(io.ReadWriteCloser) io.ReadWriteCloser) {
+	if s.client != nil {
+		s.client.WrapConn(w)
+	}
+}
+
 // WorkspaceChanges registers a new event handler.
 func (s *Subscription) WorkspaceChanges(h func(WorkspaceChange)) (Cookie, error) {
 	return register(s, &s.workspaces, WorkspaceEvent, h)
 }
 
+// WorkspaceChangesFiltered registers a new event handler that is only
+// called for events with one of the change types, or any change when
+// none are given, and whose current workspace matches pred, unless pred
+// is nil. Events no handler wants are not decoded.
+func (s *Subscription) WorkspaceChangesFiltered(h func(WorkspaceChange), pred func(*Node) bool, changes ...WorkspaceChangeType) (Cookie, error) {
+	return registerFiltered(s, &s.workspaces, WorkspaceEvent, newFilter(pred, changes), h)
+}
+
+// OutputChanges registers a new event handler.
+func (s *Subscription) OutputChanges(h func(OutputChange)) (Cookie, error) {
+	return register(s, &s.outputs, OutputEvent, h)
+}
+
 // ModeChanges registers a new event handler.
 func (s *Subscription) ModeChanges(h func(ModeChange)) (Cookie, error) {
 	return register(s, &s.modes, ModeEvent, h)
@@ -75,6 +133,31 @@ func (s *Subscription) WindowChanges(h func(WindowChange)) (Cookie, error) {
 	return register(s, &s.windows, WindowEvent, h)
 }
 
+// WindowChangesFiltered registers a new event handler that is only
+// called for events with one of the change types, or any change when
+// none are given, and whose container matches pred, unless pred is nil.
+// Events no handler wants are not decoded.
+func (s *Subscription) WindowChangesFiltered(h func(WindowChange), pred func(*Node) bool, changes ...WindowChangeType) (Cookie, error) {
+	return registerFiltered(s, &s.windows, WindowEvent, newFilter(pred, changes), h)
+}
+
+// FilterWindowChanges returns a handler that calls h for the events
+// WindowChangesFiltered would call it for, so that window events handed
+// out by other means are filtered by the same rules.
+func FilterWindowChanges(h func(WindowChange), pred func(*Node) bool, changes ...WindowChangeType) func(WindowChange) {
+	f := newFilter(pred, changes)
+	return func(wc WindowChange) {
+		if f.accepts(string(wc.Change), &wc.Container) {
+			h(wc)
+		}
+	}
+}
+
+// BarConfigUpdates registers a new event handler.
+func (s *Subscription) BarConfigUpdates(h func(BarConfigUpdate)) (Cookie, error) {
+	return register(s, &s.barconfigs, BarconfigUpdateEvent, h)
+}
+
 // BindingChanges registers a new event handler.
 func (s *Subscription) BindingChanges(h func(BindingChange)) (Cookie, error) {
 	return register(s, &s.bindings, BindingEvent, h)
@@ -90,92 +173,283 @@ func (s *Subscription) Ticks(h func(Tick)) (Cookie, error) {
 	return register(s, &s.ticks, TickEvent, h)
 }
 
+// BarStateUpdates registers a new event handler.
+func (s *Subscription) BarStateUpdates(h func(BarStateUpdate)) (Cookie, error) {
+	return register(s, &s.barstates, BarStateUpdateEvent, h)
+}
+
+// InputChanges registers a new event handler.
+func (s *Subscription) InputChanges(h func(InputChange)) (Cookie, error) {
+	return register(s, &s.inputs, InputEvent, h)
+}
+
 // RemoveHandler removes a registered event handler.
+// Handlers can be added and removed while Run is running.
+// When the last handler of an event type is gone, the Subscription
+// stops receiving that type, if its Client can redial sway.
 func (s *Subscription) RemoveHandler(c Cookie) {
 	if err := s.ensureClient(); err != nil {
 		return
 	}
 
-	delete(s.workspaces.handlers, c)
-	delete(s.modes.handlers, c)
-	delete(s.windows.handlers, c)
-	delete(s.bindings.handlers, c)
-	delete(s.shutdowns.handlers, c)
-	delete(s.ticks.handlers, c)
+	s.workspaces.remove(c)
+	s.outputs.remove(c)
+	s.modes.remove(c)
+	s.windows.remove(c)
+	s.barconfigs.remove(c)
+	s.bindings.remove(c)
+	s.shutdowns.remove(c)
+	s.ticks.remove(c)
+	s.barstates.remove(c)
+	s.inputs.remove(c)
+	s.reconnects.remove(c)
+	s.raws.remove(c)
+	s.prune()
 }
 
 // Run starts listening for events, calling the registered handlers
-// as events come in.
+// as events come in. Run returns when the Subscription is closed or
+// the connection fails, unless the Subscription is reconnecting.
 func (s *Subscription) Run() {
-	for {
-		var h Header
+	s.RunCtx(context.Background())
+}
 
-		if s.client == nil {
-			break
-		}
+// RunCtx is Run, also returning when ctx is done. It always returns
+// a *RunError telling why it stopped, after waiting for the handlers
+// still running, or still queued with OrderedDelivery, for at most the
+// drain timeout. A Subscription stopped by ctx can be run again.
+// Stopping interrupts the read of the next event with a deadline. A
+// connection that does not support deadlines, like a net.Conn does,
+// is closed instead, so that it can only run again if its Client can
+// redial.
+func (s *Subscription) RunCtx(ctx context.Context) error {
+	if s.client == nil || s.isClosed() {
+		return &RunError{Err: ErrSubscriptionClosed}
+	}
+
+	s.submx.Lock()
+	if s.running {
+		s.submx.Unlock()
+		return &RunError{Err: ErrSubscriptionRunning}
+	}
+	stopped := make(chan struct{})
+	s.running, s.stopped = true, stopped
+	early := s.early
+	s.early = nil
+	s.submx.Unlock()
+
+	defer s.endStreams()
 
-		s.clientmx.Lock()
-		if s.client == nil {
-			break
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.ctx.Done():
+			cancel()
+		case <-ctx.Done():
 		}
+	}()
 
-		if err := binary.Read(s.client, s.client.yo, &h); err != nil {
-			s.sendError(&MonitoringError{
-				fmt.Errorf("run binary.Read: %s", err)})
+	for _, m := range early {
+		s.dispatchEvent(ctx, EventPayloadType(m.pt), m.buf, m.env)
+	}
+
+	err := s.loop(ctx)
+	if s.isClosed() {
+		err = ErrSubscriptionClosed
+	}
+
+	// unblock a subscribe call waiting for its reply before taking submx
+	close(stopped)
+	doLocked(&s.submx, func() { s.running = false })
+
+	return &RunError{Err: err, Pending: s.inflight.wait(s.drain)}
+}
+
+// loop reads and dispatches events until ctx is done or the connection
+// fails for good, returning the cause. A message read completely is
+// handled even when ctx is done by then.
+func (s *Subscription) loop(ctx context.Context) error {
+	for {
+		buf, pt, err := s.readEvent(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if rerr := s.recoverRead(ctx, err); rerr != nil {
+				return rerr
+			}
 			continue
 		}
 
-		if !ValidMagic(h.Magic) {
-			continue
+		if buf != nil {
+			if uint32(pt)&eventFlag == 0 {
+				s.reply(buf)
+			} else {
+				s.dispatchEvent(ctx, EventPayloadType(pt), buf, s.envelope(buf))
+			}
 		}
 
-		buf := make([]byte, int(h.PayloadLength))
-		_, err := io.ReadFull(s.client, buf)
-		if err != nil {
-			s.sendError(&MonitoringError{
-				fmt.Errorf("run io.ReadFull: %s", err)})
-			continue
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
+	}
+}
+
+// finishTimeout is how long readEvent waits for the rest of
+// a message that was cut off when Run was stopped.
+const finishTimeout = time.Second
+
+// readEvent reads the next message, unblocking the read when ctx is done.
+// It returns a nil buffer for messages with an invalid magic.
+func (s *Subscription) readEvent(ctx context.Context) ([]byte, PayloadType, error) {
+	s.clientmx.Lock()
+	if s.client.broken {
 		s.clientmx.Unlock()
+		return nil, 0, ErrConnectionReset
+	}
+	gen := s.conngen
+	conn := s.client.ReadWriteCloser
+	stop := s.client.interruptOn(ctx)
+	s.clientmx.Unlock()
 
-		switch EventPayloadType(h.PayloadType) {
-		case WorkspaceEvent:
-			if err := handle(s.workspaces.handlers, buf); err != nil {
-				s.sendError(&MonitoringError{
-					fmt.Errorf("handle s.workspaces: %s", err)})
-			}
-			break
-		case ModeEvent:
-			if err := handle(s.modes.handlers, buf); err != nil {
-				s.sendError(&MonitoringError{
-					fmt.Errorf("handle s.modes: %s", err)})
-			}
-			break
-		case WindowEvent:
-			if err := handle(s.windows.handlers, buf); err != nil {
-				s.sendError(&MonitoringError{
-					fmt.Errorf("handle s.windows: %s", err)})
-			}
-			break
-		case BindingEvent:
-			if err := handle(s.bindings.handlers, buf); err != nil {
-				s.sendError(&MonitoringError{
-					fmt.Errorf("handle s.bindings: %s", err)})
-			}
-			break
-		case ShutdownEvent:
-			if err := handle(s.shutdowns.handlers, buf); err != nil {
-				s.sendError(&MonitoringError{
-					fmt.Errorf("handle s.shutdowns: %s", err)})
-			}
-			break
-		case TickEvent:
-			if err := handle(s.ticks.handlers, buf); err != nil {
-				s.sendError(&MonitoringError{
-					fmt.Errorf("handle s.ticks: %s", err)})
-			}
-			break
-		default:
+	m := frame{header: make([]byte, binary.Size(Header{}))}
+	err := m.read(conn, s.client.yo)
+	closed := stop()
+
+	d, canDeadline := conn.(deadliner)
+	if err != nil && m.started() && !closed && canDeadline && errors.Is(err, os.ErrDeadlineExceeded) {
+		// Run was stopped somewhere inside a message,
+		// finish it to keep the framing of the connection.
+		d.SetDeadline(time.Now().Add(finishTimeout))
+		err = m.read(conn, s.client.yo)
+		d.SetDeadline(time.Time{})
+	}
+
+	s.clientmx.Lock()
+	swapped := gen != s.conngen
+	if !swapped && (closed || (err != nil && m.started() && ctx.Err() != nil)) {
+		// the framing of the connection can no longer be trusted
+		s.client.reset()
+	}
+	s.clientmx.Unlock()
+
+	if swapped {
+		// prune replaced the connection, read the new one
+		return nil, 0, nil
+	}
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("run io.ReadFull: %w", err)
+	}
+
+	return m.payload, m.h.PayloadType, nil
+}
+
+// frame is a message being read, which can be read further
+// after the read was cut off.
+type frame struct {
+	header  []byte
+	hn      int
+	h       Header
+	payload []byte
+	pn      int
+}
+
+// read reads the rest of the message. The payload of a message
+// with an invalid magic is left nil.
+func (m *frame) read(r io.Reader, yo binary.ByteOrder) error {
+	if m.hn < len(m.header) {
+		n, err := io.ReadFull(r, m.header[m.hn:])
+		m.hn += n
+		if err != nil {
+			return err
+		}
+
+		binary.Read(bytes.NewReader(m.header), yo, &m.h)
+		if !ValidMagic(m.h.Magic) {
+			return nil
+		}
+		m.payload = make([]byte, int(m.h.PayloadLength))
+	}
+
+	n, err := io.ReadFull(r, m.payload[m.pn:])
+	m.pn += n
+	return err
+}
+
+func (m *frame) started() bool {
+	return m.hn > 0
+}
+
+func (s *Subscription) dispatchEvent(ctx context.Context, ept EventPayloadType, buf []byte, env Envelope) {
+	s.stream(ctx, ept, buf, env)
+	raw := s.handleRaw(ept, buf, env)
+
+	switch ept {
+	case WorkspaceEvent:
+		if err := handle(s, &s.workspaces, buf); err != nil {
+			s.sendError(&MonitoringError{
+				fmt.Errorf("handle s.workspaces: %s", err)})
+		}
+		break
+	case OutputEvent:
+		if err := handle(s, &s.outputs, buf); err != nil {
+			s.sendError(&MonitoringError{
+				fmt.Errorf("handle s.outputs: %s", err)})
+		}
+		break
+	case ModeEvent:
+		if err := handle(s, &s.modes, buf); err != nil {
+			s.sendError(&MonitoringError{
+				fmt.Errorf("handle s.modes: %s", err)})
+		}
+		break
+	case WindowEvent:
+		if err := handle(s, &s.windows, buf); err != nil {
+			s.sendError(&MonitoringError{
+				fmt.Errorf("handle s.windows: %s", err)})
+		}
+		break
+	case BarconfigUpdateEvent:
+		if err := handle(s, &s.barconfigs, buf); err != nil {
+			s.sendError(&MonitoringError{
+				fmt.Errorf("handle s.barconfigs: %s", err)})
+		}
+		break
+	case BindingEvent:
+		if err := handle(s, &s.bindings, buf); err != nil {
+			s.sendError(&MonitoringError{
+				fmt.Errorf("handle s.bindings: %s", err)})
+		}
+		break
+	case ShutdownEvent:
+		if err := handle(s, &s.shutdowns, buf); err != nil {
+			s.sendError(&MonitoringError{
+				fmt.Errorf("handle s.shutdowns: %s", err)})
+		}
+		break
+	case TickEvent:
+		if err := handle(s, &s.ticks, buf); err != nil {
+			s.sendError(&MonitoringError{
+				fmt.Errorf("handle s.ticks: %s", err)})
+		}
+		break
+	case BarStateUpdateEvent:
+		if err := handle(s, &s.barstates, buf); err != nil {
+			s.sendError(&MonitoringError{
+				fmt.Errorf("handle s.barstates: %s", err)})
+		}
+		break
+	case InputEvent:
+		if err := handle(s, &s.inputs, buf); err != nil {
+			s.sendError(&MonitoringError{
+				fmt.Errorf("handle s.inputs: %s", err)})
+		}
+		break
+	default:
+		if !raw {
 			s.sendError(&MonitoringError{
 				errors.New("Unknown type")})
 		}
@@ -183,20 +457,34 @@ func (s *Subscription) Run() {
 }
 
 // Close removes all registered event handlers
-// and closes the underlying Client.
+// and closes the underlying Client. A running Run returns
+// a RunError wrapping ErrSubscriptionClosed. Close does not wait
+// for the handlers, so they may call it.
 func (s *Subscription) Close() error {
-	if s.client != nil {
-		s.workspaces.reset()
-		s.modes.reset()
-		s.windows.reset()
-		s.bindings.reset()
-		s.shutdowns.reset()
-		s.ticks.reset()
-
-		err := s.client.Close()
-		s.client = nil
-		return err
+	if s.client == nil || !atomic.CompareAndSwapInt32(&s.closed, 0, 1) {
+		return nil
 	}
 
-	return nil
+	s.workspaces.reset()
+	s.outputs.reset()
+	s.modes.reset()
+	s.windows.reset()
+	s.barconfigs.reset()
+	s.bindings.reset()
+	s.shutdowns.reset()
+	s.ticks.reset()
+	s.barstates.reset()
+	s.inputs.reset()
+	s.reconnects.reset()
+	s.raws.reset()
+	s.cancel()
+	s.endStreams()
+
+	s.clientmx.Lock()
+	defer s.clientmx.Unlock()
+	return s.client.Close()
+}
+
+func (s *Subscription) isClosed() bool {
+	return atomic.LoadInt32(&s.closed) == 1
 }
@@ -1,6 +1,7 @@
 package ipc
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,41 +11,221 @@ import (
 
 type mapSyncPair[E EventArgs] struct {
 	handlers map[Cookie]func(E)
+	queues   map[Cookie]*queue[E]
+	filters  map[Cookie]*filter
 	mx       sync.Mutex
 }
 
+// filter selects the events of a filtered handler.
+type filter struct {
+	// changes holds the accepted change types, or is nil to accept all
+	changes map[string]bool
+	pred    func(*Node) bool
+}
+
+func newFilter[T ~string](pred func(*Node) bool, changes []T) *filter {
+	f := &filter{pred: pred}
+	if len(changes) > 0 {
+		f.changes = make(map[string]bool, len(changes))
+		for _, c := range changes {
+			f.changes[string(c)] = true
+		}
+	}
+
+	return f
+}
+
+func (f *filter) acceptsChange(change string) bool {
+	return f == nil || f.changes == nil || f.changes[change]
+}
+
+func (f *filter) accepts(change string, n *Node) bool {
+	if !f.acceptsChange(change) {
+		return false
+	}
+
+	return f == nil || f.pred == nil || (n != nil && f.pred(n))
+}
+
+// filterKey returns what a filter looks at in args:
+// the change type and the container, or the current workspace.
+func filterKey[E EventArgs](args E) (string, *Node) {
+	switch a := any(args).(type) {
+	case WindowChange:
+		return string(a.Change), &a.Container
+	case WorkspaceChange:
+		return string(a.Change), a.Current
+	case RawEvent:
+		return rawKey(a.Type), nil
+	}
+
+	return "", nil
+}
+
+var errSubscribeFailed = errors.New("sway error: could not subscribe to event")
+
 func register[E EventArgs](s *Subscription, msp *mapSyncPair[E], ept EventPayloadType, h func(E)) (Cookie, error) {
-	if err := s.ensureClient(); err != nil {
+	return registerFiltered(s, msp, ept, nil, h)
+}
+
+func registerFiltered[E EventArgs](s *Subscription, msp *mapSyncPair[E], ept EventPayloadType, f *filter, h func(E)) (Cookie, error) {
+	cookie, first, err := addHandler(s, msp, f, h)
+	if err != nil {
 		return EmptyCookie, err
 	}
 
+	if first {
+		s.subscribeEvent(ept)
+	}
+
+	return cookie, nil
+}
+
+// addHandler adds h to msp and reports whether it is
+// the first handler added since msp was reset.
+func addHandler[E EventArgs](s *Subscription, msp *mapSyncPair[E], f *filter, h func(E)) (Cookie, bool, error) {
+	if err := s.ensureClient(); err != nil {
+		return EmptyCookie, false, err
+	}
+
 	cookie := Cookie(atomic.AddUint32(&s.currcookie, 1))
+	first := false
+
+	var q *queue[E]
+	if s.delivery.Mode == OrderedDelivery {
+		q = newQueue(h, s.delivery, &s.inflight)
+	}
 
 	doLocked(&msp.mx, func() {
 		if msp.handlers == nil {
 			msp.handlers = map[Cookie]func(E){cookie: h}
-			s.subscribeEvent(ept)
+			first = true
 		} else {
 			msp.handlers[cookie] = h
 		}
+
+		if f != nil {
+			if msp.filters == nil {
+				msp.filters = make(map[Cookie]*filter)
+			}
+			msp.filters[cookie] = f
+		}
+
+		if q != nil {
+			if msp.queues == nil {
+				msp.queues = make(map[Cookie]*queue[E])
+			}
+			msp.queues[cookie] = q
+		}
 	})
 
-	return cookie, nil
+	return cookie, first, nil
 }
 
-func handle[E EventArgs](handlers map[Cookie]func(E), buf []byte) error {
+func handle[E EventArgs](s *Subscription, msp *mapSyncPair[E], buf []byte) error {
+	if !msp.wants(buf) {
+		return nil
+	}
+
 	args := new(E)
 	if err := json.Unmarshal(buf, args); err != nil {
 		return err
 	}
 
+	dispatch(s, msp, *args)
+	return nil
+}
+
+// wants reports whether any handler of msp may accept the event in buf,
+// looking only at its change type, so that events no handler wants
+// are not decoded.
+func (msp *mapSyncPair[E]) wants(buf []byte) bool {
+	var filters []*filter
+	all := false
+	doLocked(&msp.mx, func() {
+		for c := range msp.handlers {
+			f := msp.filters[c]
+			if f == nil || f.changes == nil {
+				all = true
+				return
+			}
+			filters = append(filters, f)
+		}
+	})
+
+	if all {
+		return true
+	}
+
+	if len(filters) == 0 {
+		return false
+	}
+
+	change, err := scanChange(buf)
+	if err != nil {
+		// let the full decode report the error
+		return true
+	}
+
+	for _, f := range filters {
+		if f.acceptsChange(change) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scanChange returns the top-level change member of the event in buf,
+// skipping over the other members without decoding them.
+func scanChange(buf []byte) (string, error) {
+	var change string
+	_, err := scanObject(buf, skipSpace(buf, 0), func(key []byte, at int) (int, error) {
+		end, err := skipValue(buf, at)
+		if err != nil || string(key) != "change" {
+			return end, err
+		}
+
+		change, err = decodeString(buf[at:end])
+		return end, err
+	})
+
+	return change, err
+}
+
+// dispatch calls every handler of msp that accepts args, either in a new
+// goroutine or through the queue of the handler.
+func dispatch[E EventArgs](s *Subscription, msp *mapSyncPair[E], args E) {
+	change, n := filterKey(args)
+
+	var handlers []func(E)
+	var queues []*queue[E]
+	doLocked(&msp.mx, func() {
+		for c, h := range msp.handlers {
+			if f := msp.filters[c]; f != nil && !f.accepts(change, n) {
+				continue
+			}
+
+			if q, ok := msp.queues[c]; ok {
+				queues = append(queues, q)
+			} else {
+				handlers = append(handlers, h)
+			}
+		}
+	})
+
+	s.inflight.add(len(handlers))
 	for _, h := range handlers {
-		go func(handler func(E)) {
-			handler(*args)
+		go func(h func(E)) {
+			defer s.inflight.done()
+			h(args)
 		}(h)
 	}
 
-	return nil
+	// pushing can block, so it must not hold msp.mx
+	for _, q := range queues {
+		q.push(args)
+	}
 }
 
 func doLocked(m *sync.Mutex, action func()) {
@@ -55,18 +236,14 @@ func doLocked(m *sync.Mutex, action func()) {
 
 func (s *Subscription) subscribeEvent(event EventPayloadType) {
 	if s.client != nil {
-		res, err := s.client.Subscribe(event)
-		if err != nil {
-			s.sendError(&MonitoringError{fmt.Errorf("subscribeEvent s.client.Subscribe: %s", err)})
-		}
-		if !res.Success {
-			s.sendError(&MonitoringError{errors.New("sway error: could not subscribe to event")})
+		if err := s.subscribe(event); err != nil {
+			s.sendError(&MonitoringError{fmt.Errorf("subscribeEvent s.subscribe: %s", err)})
 		}
 	}
 }
 
 func (s *Subscription) ensureClient() error {
-	if s.client == nil {
+	if s.client == nil || s.isClosed() {
 		return errors.New("Cannot add handlers on a closed subscription")
 	}
 
@@ -74,6 +251,9 @@ func (s *Subscription) ensureClient() error {
 }
 
 func (s *Subscription) sendError(err error) {
+	s.errorsmx.Lock()
+	defer s.errorsmx.Unlock()
+
 	for _, e := range s.errors {
 		go func(ch chan<- error) {
 			ch <- err
@@ -81,8 +261,112 @@ func (s *Subscription) sendError(err error) {
 	}
 }
 
+func (msp *mapSyncPair[E]) remove(c Cookie) {
+	doLocked(&msp.mx, func() {
+		delete(msp.handlers, c)
+		delete(msp.filters, c)
+		if q, ok := msp.queues[c]; ok {
+			q.close()
+			delete(msp.queues, c)
+		}
+	})
+}
+
 func (msp *mapSyncPair[E]) reset() {
 	doLocked(&msp.mx, func() {
 		msp.handlers = nil
+		msp.filters = nil
+		for _, q := range msp.queues {
+			q.close()
+		}
+		msp.queues = nil
+	})
+}
+
+// active reports whether msp has handlers, forgetting
+// the subscription of an emptied msp so the next handler
+// subscribes again.
+func (msp *mapSyncPair[E]) active() bool {
+	has := false
+	doLocked(&msp.mx, func() {
+		if len(msp.handlers) == 0 {
+			msp.handlers = nil
+		} else {
+			has = true
+		}
 	})
+
+	return has
+}
+
+// subscribedEvents returns every sway event type that has handlers,
+// including raw handlers, or is wanted by the channels of Events.
+func (s *Subscription) subscribedEvents() []EventPayloadType {
+	pairs := []struct {
+		active func() bool
+		ept    EventPayloadType
+	}{
+		{s.workspaces.active, WorkspaceEvent},
+		{s.outputs.active, OutputEvent},
+		{s.modes.active, ModeEvent},
+		{s.windows.active, WindowEvent},
+		{s.barconfigs.active, BarconfigUpdateEvent},
+		{s.bindings.active, BindingEvent},
+		{s.shutdowns.active, ShutdownEvent},
+		{s.ticks.active, TickEvent},
+		{s.barstates.active, BarStateUpdateEvent},
+		{s.inputs.active, InputEvent},
+	}
+
+	evts := make([]EventPayloadType, 0, len(pairs))
+	for _, p := range pairs {
+		if p.active() {
+			evts = append(evts, p.ept)
+		}
+	}
+
+	for _, ept := range append(s.streamEvents(), s.rawEvents()...) {
+		if !containsEvent(evts, ept) {
+			evts = append(evts, ept)
+		}
+	}
+
+	return evts
+}
+
+func containsEvent(evts []EventPayloadType, ept EventPayloadType) bool {
+	for _, e := range evts {
+		if e == ept {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recoverRead reports a failed read and, in reconnecting mode,
+// reconnects the Subscription. It returns why Run should stop, or nil.
+func (s *Subscription) recoverRead(ctx context.Context, err error) error {
+	s.clientmx.Lock()
+	defer s.clientmx.Unlock()
+
+	if s.isClosed() {
+		return ErrSubscriptionClosed
+	}
+
+	s.sendError(&MonitoringError{err})
+	if s.client.backoff == nil {
+		return err
+	}
+
+	if err := s.reconnect(ctx); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		s.sendError(&MonitoringError{fmt.Errorf("reconnect: %s", err)})
+		return err
+	}
+
+	return nil
 }
@@ -1,6 +1,9 @@
 package ipc
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 type MonitoringError struct {
 	err error
@@ -17,3 +20,29 @@ func (e *MonitoringError) Error() string {
 func (e *MonitoringError) Unwrap() error {
 	return e.err
 }
+
+// ErrSubscriptionClosed is the cause of the RunError returned by Run
+// after the Subscription was closed.
+var ErrSubscriptionClosed = errors.New("ipc: subscription closed")
+
+// RunError tells why Run returned. Err is ErrSubscriptionClosed,
+// the error of the context given to RunCtx, or the error that broke
+// the connection.
+type RunError struct {
+	Err error
+	// Pending is the number of events the handlers had not finished
+	// with when Run stopped waiting for them.
+	Pending int
+}
+
+func (e *RunError) Error() string {
+	if e.Pending > 0 {
+		return fmt.Sprintf("subscription run: %v (%d events pending)", e.Err, e.Pending)
+	}
+
+	return fmt.Sprintf("subscription run: %v", e.Err)
+}
+
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,178 @@
+package ipc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// eventsBuffer is the capacity of the channels returned by Events.
+const eventsBuffer = 16
+
+// allEvents are the sway events Events delivers when no types are given.
+var allEvents = []EventPayloadType{
+	WorkspaceEvent, OutputEvent, ModeEvent, WindowEvent, BarconfigUpdateEvent,
+	BindingEvent, ShutdownEvent, TickEvent, BarStateUpdateEvent, InputEvent,
+}
+
+type stream struct {
+	types  map[EventPayloadType]bool
+	ch     chan Event
+	sendmx sync.Mutex
+	ctx    context.Context
+	done   chan struct{}
+}
+
+// send sends evt on the channel, giving up when the stream ends
+// or ctx is done.
+func (st *stream) send(ctx context.Context, evt Event) {
+	st.sendmx.Lock()
+	defer st.sendmx.Unlock()
+
+	select {
+	case <-st.done:
+		return
+	default:
+	}
+
+	select {
+	case st.ch <- evt:
+	case <-st.done:
+	case <-st.ctx.Done():
+	case <-ctx.Done():
+	}
+}
+
+// end closes the stream. done is closed first to wake a blocked send,
+// which holds sendmx, so that ch is not closed while it is sent on.
+func (st *stream) end() {
+	close(st.done)
+	doLocked(&st.sendmx, func() { close(st.ch) })
+}
+
+// Events returns a channel that yields the events of the given types,
+// or of every type when none are given, in the order sway sent them.
+//
+// Unlike handlers, which each run in their own goroutine, events are sent
+// on the channel by Run itself: Run waits for the receiver once the
+// channel buffer is full, so the receiver must keep reading until it is
+// done and then cancel ctx. The channel is closed when ctx is done,
+// the Subscription is closed or Run returns.
+func (s *Subscription) Events(ctx context.Context, types ...EventPayloadType) (<-chan Event, error) {
+	if err := s.ensureClient(); err != nil {
+		return nil, err
+	}
+
+	if len(types) == 0 {
+		types = allEvents
+	}
+
+	st := &stream{
+		types: make(map[EventPayloadType]bool, len(types)),
+		ch:    make(chan Event, eventsBuffer),
+		ctx:   ctx,
+		done:  make(chan struct{}),
+	}
+
+	for _, t := range types {
+		st.types[t] = true
+	}
+
+	cookie := Cookie(atomic.AddUint32(&s.currcookie, 1))
+	doLocked(&s.streamsmx, func() {
+		if s.streams == nil {
+			s.streams = make(map[Cookie]*stream)
+		}
+		s.streams[cookie] = st
+	})
+
+	if err := s.subscribe(types...); err != nil {
+		s.endStream(cookie)
+		return nil, err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.ctx.Done():
+		case <-st.done:
+			return
+		}
+		s.endStream(cookie)
+		s.prune()
+	}()
+
+	return st.ch, nil
+}
+
+// stream sends an event to the channels of Events that want it,
+// giving up on a channel when ctx is done. The channels are sent on
+// outside of streamsmx, so a slow receiver does not hold up Events.
+func (s *Subscription) stream(ctx context.Context, ept EventPayloadType, buf []byte, env Envelope) {
+	var streams []*stream
+	doLocked(&s.streamsmx, func() {
+		for _, st := range s.streams {
+			if st.types[ept] {
+				streams = append(streams, st)
+			}
+		}
+	})
+
+	if len(streams) == 0 {
+		return
+	}
+
+	evt, err := decodeEvent(ept, buf)
+	if err != nil {
+		s.sendError(&MonitoringError{fmt.Errorf("stream %v: %s", ept, err)})
+		return
+	}
+	evt.Envelope = env
+
+	for _, st := range streams {
+		st.send(ctx, evt)
+	}
+}
+
+// streamEvents returns the sway event types that the channels of Events want.
+func (s *Subscription) streamEvents() []EventPayloadType {
+	var evts []EventPayloadType
+	doLocked(&s.streamsmx, func() {
+		seen := make(map[EventPayloadType]bool)
+		for _, st := range s.streams {
+			for t := range st.types {
+				if !seen[t] {
+					seen[t] = true
+					evts = append(evts, t)
+				}
+			}
+		}
+	})
+
+	return evts
+}
+
+func (s *Subscription) endStream(c Cookie) {
+	var st *stream
+	doLocked(&s.streamsmx, func() {
+		st = s.streams[c]
+		delete(s.streams, c)
+	})
+
+	if st != nil {
+		st.end()
+	}
+}
+
+func (s *Subscription) endStreams() {
+	var streams map[Cookie]*stream
+	doLocked(&s.streamsmx, func() {
+		streams = s.streams
+		s.streams = nil
+	})
+
+	for _, st := range streams {
+		st.end()
+	}
+}
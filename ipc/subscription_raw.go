@@ -0,0 +1,101 @@
+package ipc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// RawEvents registers a new event handler that gets the undecoded payload
+// of every event of the given types, including types this package does
+// not model. Types it does not know must be named with RegisterEventName
+// first, so they can be subscribed to. When no types are given, the handler
+// gets every event the Subscription receives, without subscribing to more.
+func (s *Subscription) RawEvents(types []EventPayloadType, h func(EventPayloadType, json.RawMessage)) (Cookie, error) {
+	return s.addRaw(types, func(e RawEvent) {
+		h(e.Type, e.Payload)
+	})
+}
+
+// addRaw adds h to the raw handlers and subscribes to types.
+func (s *Subscription) addRaw(types []EventPayloadType, h func(RawEvent)) (Cookie, error) {
+	for _, t := range types {
+		if t.EventName() == "" {
+			return EmptyCookie, fmt.Errorf("no name for event type %#x, use RegisterEventName", uint32(t))
+		}
+	}
+
+	keys := make([]string, len(types))
+	for i, t := range types {
+		keys[i] = rawKey(t)
+	}
+
+	cookie, _, err := addHandler(s, &s.raws, newFilter(nil, keys), h)
+	if err != nil {
+		return EmptyCookie, err
+	}
+
+	if len(types) > 0 {
+		if err := s.subscribe(types...); err != nil {
+			s.raws.remove(cookie)
+			return EmptyCookie, err
+		}
+	}
+
+	return cookie, nil
+}
+
+// On registers a new event handler for events of type ept, decoding
+// their payload into E. It works for any event, like RawEvents, but
+// with a typed handler. Decoding errors are sent to the Errors channels.
+func On[E any](s *Subscription, ept EventPayloadType, h func(E)) (Cookie, error) {
+	return s.RawEvents([]EventPayloadType{ept}, func(t EventPayloadType, payload json.RawMessage) {
+		e := new(E)
+		if err := json.Unmarshal(payload, e); err != nil {
+			s.sendError(&MonitoringError{fmt.Errorf("On %v: %s", t, err)})
+			return
+		}
+
+		h(*e)
+	})
+}
+
+// handleRaw dispatches an event to the raw handlers that want it.
+// It reports whether there were any.
+func (s *Subscription) handleRaw(ept EventPayloadType, buf []byte, env Envelope) bool {
+	key := rawKey(ept)
+	wanted := false
+	doLocked(&s.raws.mx, func() {
+		for c := range s.raws.handlers {
+			if s.raws.filters[c].acceptsChange(key) {
+				wanted = true
+				return
+			}
+		}
+	})
+
+	if wanted {
+		dispatch(s, &s.raws, RawEvent{ept, json.RawMessage(buf), env})
+	}
+
+	return wanted
+}
+
+// rawEvents returns the event types the raw handlers subscribed to.
+func (s *Subscription) rawEvents() []EventPayloadType {
+	var evts []EventPayloadType
+	doLocked(&s.raws.mx, func() {
+		for _, f := range s.raws.filters {
+			for key := range f.changes {
+				t, _ := strconv.ParseUint(key, 10, 32)
+				evts = append(evts, EventPayloadType(t))
+			}
+		}
+	})
+
+	return evts
+}
+
+func rawKey(t EventPayloadType) string {
+	return strconv.FormatUint(uint64(t), 10)
+}
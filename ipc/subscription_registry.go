@@ -0,0 +1,274 @@
+package ipc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrSubscriptionRunning is the cause of the RunError returned by Run
+// when the Subscription is already running.
+var ErrSubscriptionRunning = errors.New("ipc: subscription already running")
+
+// eventFlag is set in the type of every event, and in no reply.
+const eventFlag = 0x80000000
+
+// message is an undecoded message read from sway.
+type message struct {
+	pt  PayloadType
+	buf []byte
+	env Envelope
+}
+
+// subscribe makes sway send the events of the given types that
+// the Subscription does not receive yet.
+//
+// While Run is reading the connection, the request is written here
+// but its reply is read by Run and handed over through s.replies,
+// so the two never compete for the same message.
+func (s *Subscription) subscribe(types ...EventPayloadType) error {
+	s.submx.Lock()
+	defer s.submx.Unlock()
+
+	var missing []EventPayloadType
+	doLocked(&s.clientmx, func() {
+		for _, t := range types {
+			if !s.subscribed[t] && !containsEvent(missing, t) {
+				missing = append(missing, t)
+			}
+		}
+	})
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	var res *Result
+	var err error
+	if s.running {
+		res, err = s.subscribeRunning(missing)
+	} else {
+		res, err = s.subscribeIdle(missing)
+	}
+
+	if err == nil && !res.Success {
+		err = errSubscribeFailed
+	}
+
+	if err != nil {
+		return err
+	}
+
+	doLocked(&s.clientmx, func() {
+		for _, t := range missing {
+			s.subscribed[t] = true
+		}
+	})
+
+	return nil
+}
+
+// subscribeIdle sends a subscribe request while Run is not reading the
+// connection. Events already subscribed to may arrive before the reply,
+// they are kept in s.early for the next Run.
+func (s *Subscription) subscribeIdle(types []EventPayloadType) (*Result, error) {
+	payload, err := json.Marshal(eventNames(types))
+	if err != nil {
+		// panic here because this shouldn't be possible
+		panic(err)
+	}
+
+	ctx := s.ctx
+	if s.client.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.client.timeout)
+		defer cancel()
+	}
+
+	s.clientmx.Lock()
+	defer s.clientmx.Unlock()
+
+	if err := s.client.ensureConn(ctx); err != nil {
+		return nil, err
+	}
+
+	stop := s.client.interruptOn(ctx)
+	err = s.client.write(SubscribeMessage, payload)
+	var buf []byte
+	for err == nil {
+		var pt PayloadType
+		pt, buf, err = s.client.readMessage()
+		if err != nil || uint32(pt)&eventFlag == 0 {
+			break
+		}
+
+		s.early = append(s.early, message{pt, buf, s.envelope(buf)})
+	}
+
+	if closed := stop(); closed || (err != nil && interrupted(ctx, err)) {
+		s.client.reset()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	res := new(Result)
+	if err := json.Unmarshal(buf, res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// subscribeRunning sends a subscribe request while Run is reading
+// the connection and waits for Run to pass on the reply.
+func (s *Subscription) subscribeRunning(types []EventPayloadType) (*Result, error) {
+	payload, err := json.Marshal(eventNames(types))
+	if err != nil {
+		// panic here because this shouldn't be possible
+		panic(err)
+	}
+
+	// forget a reply nobody waited for, and an earlier reconnect
+	select {
+	case <-s.replies:
+	default:
+	}
+	select {
+	case <-s.redialed:
+	default:
+	}
+
+	s.clientmx.Lock()
+	err = s.client.write(SubscribeMessage, payload)
+	s.clientmx.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf []byte
+	select {
+	case buf = <-s.replies:
+	case <-s.stopped:
+		// Run stopped, maybe before it read the reply
+		select {
+		case buf = <-s.replies:
+		default:
+			s.clientmx.Lock()
+			buf, err = s.client.read()
+			s.clientmx.Unlock()
+			if err != nil {
+				return nil, err
+			}
+		}
+	case <-s.redialed:
+		// the reply may be lost with the old connection, but the new
+		// one is subscribed to every type that has handlers
+		subscribed := true
+		doLocked(&s.clientmx, func() {
+			for _, t := range types {
+				subscribed = subscribed && s.subscribed[t]
+			}
+		})
+
+		return &Result{Success: subscribed}, nil
+	case <-s.ctx.Done():
+		return nil, ErrSubscriptionClosed
+	}
+
+	res := new(Result)
+	if err := json.Unmarshal(buf, res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// reply hands a reply read by Run to the waiting subscribe call.
+func (s *Subscription) reply(buf []byte) {
+	select {
+	case s.replies <- buf:
+	default:
+		s.sendError(&MonitoringError{fmt.Errorf("unexpected reply: %s", buf)})
+	}
+}
+
+// prune stops receiving the event types that nothing wants anymore,
+// in the background. Sway cannot unsubscribe, so it switches to a fresh
+// connection that subscribes to the remaining types only. Events sent
+// during the switch may be lost. A Client that cannot redial keeps
+// receiving the types, and the Subscription drops their events.
+func (s *Subscription) prune() {
+	if s.client == nil || s.client.dial == nil || s.isClosed() {
+		return
+	}
+
+	go s.pruneNow()
+}
+
+func (s *Subscription) pruneNow() {
+	s.submx.Lock()
+	defer s.submx.Unlock()
+
+	evts := s.subscribedEvents()
+	stale := false
+	doLocked(&s.clientmx, func() {
+		for t := range s.subscribed {
+			if !containsEvent(evts, t) {
+				stale = true
+			}
+		}
+	})
+
+	if !stale {
+		return
+	}
+
+	if err := s.resubscribe(evts); err != nil && !s.isClosed() {
+		s.sendError(&MonitoringError{fmt.Errorf("prune: %s", err)})
+	}
+}
+
+// resubscribe replaces the connection with a new one
+// subscribed to evts. Run moves on to the new connection.
+func (s *Subscription) resubscribe(evts []EventPayloadType) error {
+	conn, err := s.client.dialConn()
+	if err != nil {
+		return err
+	}
+
+	fresh := NewClient(conn, s.client.yo)
+	fresh.timeout = s.client.timeout
+	if len(evts) > 0 {
+		res, err := fresh.SubscribeCtx(s.ctx, evts...)
+		if err == nil && !res.Success {
+			err = errSubscribeFailed
+		}
+
+		if err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	s.clientmx.Lock()
+	if s.isClosed() {
+		s.clientmx.Unlock()
+		conn.Close()
+		return ErrSubscriptionClosed
+	}
+
+	old := s.client.ReadWriteCloser
+	s.client.ReadWriteCloser = conn
+	s.client.broken = false
+	s.conngen++
+	s.subscribed = make(map[EventPayloadType]bool, len(evts))
+	for _, t := range evts {
+		s.subscribed[t] = true
+	}
+	s.clientmx.Unlock()
+
+	return old.Close()
+}
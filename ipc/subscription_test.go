@@ -1,14 +1,19 @@
 package ipc_test
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"encoding/json"
+	"io"
 	"net"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/libanvl/swager/ipc"
 	"github.com/libanvl/swager/test"
+	"github.com/libanvl/swager/test/swaytest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -41,6 +46,16 @@ func TestSubscriptionWithNilClient(t *testing.T) {
 		"Ticks":            func(s *ipc.Subscription) (any, error) { return s.Ticks(nil) },
 		"WindowChanges":    func(s *ipc.Subscription) (any, error) { return s.WindowChanges(nil) },
 		"WorkspaceChanges": func(s *ipc.Subscription) (any, error) { return s.WorkspaceChanges(nil) },
+		"BarConfigUpdates": func(s *ipc.Subscription) (any, error) { return s.BarConfigUpdates(nil) },
+		"BarStateUpdates":  func(s *ipc.Subscription) (any, error) { return s.BarStateUpdates(nil) },
+		"InputChanges":     func(s *ipc.Subscription) (any, error) { return s.InputChanges(nil) },
+		"OutputChanges":    func(s *ipc.Subscription) (any, error) { return s.OutputChanges(nil) },
+		"WindowChangesFiltered": func(s *ipc.Subscription) (any, error) {
+			return s.WindowChangesFiltered(nil, nil, ipc.NewWindow)
+		},
+		"WorkspaceChangesFiltered": func(s *ipc.Subscription) (any, error) {
+			return s.WorkspaceChangesFiltered(nil, nil, ipc.InitWorkspace)
+		},
 	}
 
 	for name, tc := range tests {
@@ -60,6 +75,16 @@ func TestRemoveHandler(t *testing.T) {
 		"Ticks":            func(s *ipc.Subscription) (ipc.Cookie, error) { return s.Ticks(nil) },
 		"WindowChanges":    func(s *ipc.Subscription) (ipc.Cookie, error) { return s.WindowChanges(nil) },
 		"WorkspaceChanges": func(s *ipc.Subscription) (ipc.Cookie, error) { return s.WorkspaceChanges(nil) },
+		"BarConfigUpdates": func(s *ipc.Subscription) (ipc.Cookie, error) { return s.BarConfigUpdates(nil) },
+		"BarStateUpdates":  func(s *ipc.Subscription) (ipc.Cookie, error) { return s.BarStateUpdates(nil) },
+		"InputChanges":     func(s *ipc.Subscription) (ipc.Cookie, error) { return s.InputChanges(nil) },
+		"OutputChanges":    func(s *ipc.Subscription) (ipc.Cookie, error) { return s.OutputChanges(nil) },
+		"WindowChangesFiltered": func(s *ipc.Subscription) (ipc.Cookie, error) {
+			return s.WindowChangesFiltered(nil, nil, ipc.NewWindow)
+		},
+		"WorkspaceChangesFiltered": func(s *ipc.Subscription) (ipc.Cookie, error) {
+			return s.WorkspaceChangesFiltered(nil, nil, ipc.InitWorkspace)
+		},
 	}
 
 	for name, tc := range tests {
@@ -90,6 +115,10 @@ func TestClose(t *testing.T) {
 		"Ticks":            func(s *ipc.Subscription) (ipc.Cookie, error) { return s.Ticks(nil) },
 		"WindowChanges":    func(s *ipc.Subscription) (ipc.Cookie, error) { return s.WindowChanges(nil) },
 		"WorkspaceChanges": func(s *ipc.Subscription) (ipc.Cookie, error) { return s.WorkspaceChanges(nil) },
+		"BarConfigUpdates": func(s *ipc.Subscription) (ipc.Cookie, error) { return s.BarConfigUpdates(nil) },
+		"BarStateUpdates":  func(s *ipc.Subscription) (ipc.Cookie, error) { return s.BarStateUpdates(nil) },
+		"InputChanges":     func(s *ipc.Subscription) (ipc.Cookie, error) { return s.InputChanges(nil) },
+		"OutputChanges":    func(s *ipc.Subscription) (ipc.Cookie, error) { return s.OutputChanges(nil) },
 	}
 
 	for name, tc := range tests {
@@ -144,6 +173,22 @@ func TestHandlerRegistration(t *testing.T) {
 			"workspace",
 			func(s *ipc.Subscription) (ipc.Cookie, error) { return s.WorkspaceChanges(nil) },
 		},
+		"BarConfigUpdates": {
+			"barconfig_update",
+			func(s *ipc.Subscription) (ipc.Cookie, error) { return s.BarConfigUpdates(nil) },
+		},
+		"BarStateUpdates": {
+			"bar_state_update",
+			func(s *ipc.Subscription) (ipc.Cookie, error) { return s.BarStateUpdates(nil) },
+		},
+		"InputChanges": {
+			"input",
+			func(s *ipc.Subscription) (ipc.Cookie, error) { return s.InputChanges(nil) },
+		},
+		"OutputChanges": {
+			"output",
+			func(s *ipc.Subscription) (ipc.Cookie, error) { return s.OutputChanges(nil) },
+		},
 	}
 
 	for name, tc := range tests {
@@ -257,6 +302,63 @@ func TestRun(t *testing.T) {
 				})
 			},
 		},
+		"BarConfigUpdates": {
+			ipc.BarconfigUpdateEvent,
+			ipc.BarConfigUpdate{
+				BarConfig: ipc.BarConfig{
+					ID:          "bar-0",
+					Mode:        ipc.HideBarMode,
+					HiddenState: ipc.ShowBarHiddenState,
+				},
+			},
+			func(s *ipc.Subscription, a *assert.Assertions, x any) {
+				s.BarConfigUpdates(func(bc ipc.BarConfigUpdate) {
+					a.EqualValues(x, bc)
+					s.Close()
+				})
+			},
+		},
+		"BarStateUpdates": {
+			ipc.BarStateUpdateEvent,
+			ipc.BarStateUpdate{
+				ID:                "bar-0",
+				VisibleByModifier: true,
+			},
+			func(s *ipc.Subscription, a *assert.Assertions, x any) {
+				s.BarStateUpdates(func(bs ipc.BarStateUpdate) {
+					a.EqualValues(x, bs)
+					s.Close()
+				})
+			},
+		},
+		"InputChanges": {
+			ipc.InputEvent,
+			ipc.InputChange{
+				Change: ipc.XkbLayoutInput,
+				Input: ipc.Input{
+					Identifier: "1:1:TestKeyboard",
+					Type:       ipc.KeyboardInputDevice,
+				},
+			},
+			func(s *ipc.Subscription, a *assert.Assertions, x any) {
+				s.InputChanges(func(ic ipc.InputChange) {
+					a.EqualValues(x, ic)
+					s.Close()
+				})
+			},
+		},
+		"OutputChanges": {
+			ipc.OutputEvent,
+			ipc.OutputChange{
+				Change: ipc.UnspecifiedOutput,
+			},
+			func(s *ipc.Subscription, a *assert.Assertions, x any) {
+				s.OutputChanges(func(oc ipc.OutputChange) {
+					a.EqualValues(x, oc)
+					s.Close()
+				})
+			},
+		},
 	}
 
 	for name, tc := range tests {
@@ -292,3 +394,824 @@ func TestMonitoringError(t *testing.T) {
 	assert.Contains(t, x.Error(), assert.AnError.Error())
 	assert.Equal(t, assert.AnError, x.Unwrap())
 }
+
+func TestReconnect(t *testing.T) {
+	tmpsocket := t.TempDir() + "/uds"
+	l, err := net.Listen("unix", tmpsocket)
+	require.Nil(t, err)
+	defer l.Close()
+
+	success, err := json.Marshal(ipc.Result{Success: true})
+	require.Nil(t, err)
+	event, err := json.Marshal(ipc.WindowChange{Change: ipc.NewWindow})
+	require.Nil(t, err)
+
+	resubscribed := make(chan string, 1)
+	go func() {
+		for i := 0; i < 2; i++ {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			var h ipc.Header
+			if err := binary.Read(conn, binary.LittleEndian, &h); err != nil {
+				return
+			}
+			payload := make([]byte, h.PayloadLength)
+			io.ReadFull(conn, payload)
+			binary.Write(conn, binary.LittleEndian, ipc.NewHeader(ipc.SubscribeMessage, len(success)))
+			conn.Write(success)
+
+			if i == 0 {
+				// simulate sway going away
+				conn.Close()
+				continue
+			}
+
+			resubscribed <- string(payload)
+			binary.Write(conn, binary.LittleEndian, ipc.NewHeader(ipc.PayloadType(ipc.WindowEvent), len(event)))
+			conn.Write(event)
+		}
+	}()
+
+	client, err := ipc.ConnectCustom(tmpsocket, binary.LittleEndian)
+	require.Nil(t, err)
+	sub := ipc.SubscribeCustom(client)
+	sub.SetReconnect(ipc.Backoff{Min: time.Millisecond, Max: 10 * time.Millisecond, Attempts: 10})
+	defer sub.Close()
+
+	windows := make(chan ipc.WindowChange, 1)
+	cookie, err := sub.WindowChanges(func(wc ipc.WindowChange) { windows <- wc })
+	require.Nil(t, err)
+	reconnected := make(chan ipc.Reconnected, 1)
+	_, err = sub.Reconnects(func(r ipc.Reconnected) { reconnected <- r })
+	require.Nil(t, err)
+
+	go sub.Run()
+
+	select {
+	case r := <-reconnected:
+		assert.GreaterOrEqual(t, r.Attempts, 1)
+	case <-time.After(time.Second):
+		t.Fatal("no reconnected notification")
+	}
+
+	select {
+	case payload := <-resubscribed:
+		assert.Contains(t, payload, "window")
+	case <-time.After(time.Second):
+		t.Fatal("events were not subscribed again")
+	}
+
+	select {
+	case wc := <-windows:
+		assert.Equal(t, ipc.NewWindow, wc.Change)
+	case <-time.After(time.Second):
+		t.Fatal("handler registered before reconnect was not called")
+	}
+
+	sub.RemoveHandler(cookie)
+}
+
+func TestSubscribeLostReply(t *testing.T) {
+	tmpsocket := t.TempDir() + "/uds"
+	l, err := net.Listen("unix", tmpsocket)
+	require.Nil(t, err)
+	defer l.Close()
+
+	success, err := json.Marshal(ipc.Result{Success: true})
+	require.Nil(t, err)
+	event, err := json.Marshal(ipc.WindowChange{Change: ipc.NewWindow})
+	require.Nil(t, err)
+
+	read := func(conn net.Conn) string {
+		var h ipc.Header
+		if err := binary.Read(conn, binary.LittleEndian, &h); err != nil {
+			return ""
+		}
+		payload := make([]byte, h.PayloadLength)
+		io.ReadFull(conn, payload)
+		return string(payload)
+	}
+	reply := func(conn net.Conn) {
+		binary.Write(conn, binary.LittleEndian, ipc.NewHeader(ipc.SubscribeMessage, len(success)))
+		conn.Write(success)
+	}
+
+	resubscribed := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		read(conn)
+		reply(conn)
+		binary.Write(conn, binary.LittleEndian, ipc.NewHeader(ipc.PayloadType(ipc.WindowEvent), len(event)))
+		conn.Write(event)
+
+		// lose the reply to the second subscribe with the connection
+		read(conn)
+		conn.Close()
+
+		conn, err = l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		payload := read(conn)
+		reply(conn)
+		resubscribed <- payload
+		read(conn)
+	}()
+
+	client, err := ipc.ConnectCustom(tmpsocket, binary.LittleEndian)
+	require.Nil(t, err)
+	sub := ipc.SubscribeCustom(client)
+	sub.SetReconnect(ipc.Backoff{Min: time.Millisecond, Max: 10 * time.Millisecond, Attempts: 10})
+	defer sub.Close()
+
+	errs := make(chan error, 10)
+	sub.Errors(errs)
+	running := make(chan struct{}, 1)
+	_, err = sub.WindowChanges(func(ipc.WindowChange) { running <- struct{}{} })
+	require.Nil(t, err)
+	go sub.Run()
+	<-running
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := sub.Ticks(func(ipc.Tick) {})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("subscribe did not return after reconnecting")
+	}
+
+	assert.Contains(t, <-resubscribed, "tick")
+	for len(errs) > 0 {
+		assert.NotContains(t, (<-errs).Error(), "subscribe")
+	}
+}
+
+func TestRunStopsOnReadError(t *testing.T) {
+	conn := test.NewMockConnection(t)
+	conn.EOFWhenEmpty = true
+	conn.PushNextReadError(io.EOF)
+	sub := ipc.SubscribeCustom(ipc.NewClient(conn, binary.LittleEndian))
+
+	errs := make(chan error, 1)
+	sub.Errors(errs)
+
+	done := make(chan struct{})
+	go func() {
+		sub.Run()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after the connection failed")
+	}
+
+	assert.ErrorContains(t, <-errs, "EOF")
+}
+
+func TestRunCtx(t *testing.T) {
+	tests := map[string]struct {
+		stop     func(*ipc.Subscription, context.CancelFunc)
+		expected error
+	}{
+		"Cancel": {func(_ *ipc.Subscription, cancel context.CancelFunc) { cancel() }, context.Canceled},
+		"Close":  {func(s *ipc.Subscription, _ context.CancelFunc) { s.Close() }, ipc.ErrSubscriptionClosed},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := swaytest.NewServer(t)
+			client, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+			require.Nil(t, err)
+			sub := ipc.SubscribeCustom(client)
+			defer sub.Close()
+
+			ticks := make(chan ipc.Tick, 1)
+			_, err = sub.Ticks(func(tick ipc.Tick) { ticks <- tick })
+			require.Nil(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan error, 1)
+			go func() { done <- sub.RunCtx(ctx) }()
+
+			server.Emit(ipc.TickEvent, ipc.Tick{Payload: "first"})
+			assert.Equal(t, "first", (<-ticks).Payload)
+
+			tc.stop(sub, cancel)
+
+			select {
+			case err := <-done:
+				var runErr *ipc.RunError
+				require.ErrorAs(t, err, &runErr)
+				assert.ErrorIs(t, err, tc.expected)
+				assert.Zero(t, runErr.Pending)
+			case <-time.After(time.Second):
+				t.Fatal("RunCtx did not return")
+			}
+		})
+	}
+}
+
+func TestRunCtxAgain(t *testing.T) {
+	server := swaytest.NewServer(t)
+	client, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+	require.Nil(t, err)
+	sub := ipc.SubscribeCustom(client)
+	defer sub.Close()
+
+	ticks := make(chan ipc.Tick, 1)
+	_, err = sub.Ticks(func(tick ipc.Tick) { ticks <- tick })
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, sub.RunCtx(ctx), context.Canceled)
+
+	go sub.Run()
+	server.Emit(ipc.TickEvent, ipc.Tick{Payload: "again"})
+
+	select {
+	case tick := <-ticks:
+		assert.Equal(t, "again", tick.Payload)
+	case <-time.After(time.Second):
+		t.Fatal("no event after running again")
+	}
+}
+
+func TestRunCtxFinishesMessage(t *testing.T) {
+	cconn, sconn := net.Pipe()
+	defer sconn.Close()
+	sconn.SetDeadline(time.Now().Add(5 * time.Second))
+	sub := ipc.SubscribeCustom(ipc.NewClient(cconn, binary.LittleEndian))
+	defer sub.Close()
+
+	frame := func(pt ipc.PayloadType, v any) []byte {
+		payload, err := json.Marshal(v)
+		require.Nil(t, err)
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.LittleEndian, ipc.NewHeader(pt, len(payload)))
+		buf.Write(payload)
+		return buf.Bytes()
+	}
+
+	go func() {
+		var h ipc.Header
+		binary.Read(sconn, binary.LittleEndian, &h)
+		io.ReadFull(sconn, make([]byte, h.PayloadLength))
+		sconn.Write(frame(ipc.SubscribeMessage, ipc.Result{Success: true}))
+	}()
+
+	ticks := make(chan ipc.Tick, 2)
+	_, err := sub.Ticks(func(tick ipc.Tick) { ticks <- tick })
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- sub.RunCtx(ctx) }()
+
+	// stop Run in the middle of a message
+	first := frame(ipc.PayloadType(ipc.TickEvent), ipc.Tick{Payload: "first"})
+	_, err = sconn.Write(first[:20])
+	require.Nil(t, err)
+	cancel()
+	_, err = sconn.Write(first[20:])
+	require.Nil(t, err)
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("RunCtx did not return")
+	}
+
+	select {
+	case tick := <-ticks:
+		assert.Equal(t, "first", tick.Payload)
+	case <-time.After(time.Second):
+		t.Fatal("the event read before the cancel was dropped")
+	}
+
+	// the connection was not reset, so it can run again without redialing
+	go sub.Run()
+	_, err = sconn.Write(frame(ipc.PayloadType(ipc.TickEvent), ipc.Tick{Payload: "second"}))
+	require.Nil(t, err)
+
+	select {
+	case tick := <-ticks:
+		assert.Equal(t, "second", tick.Payload)
+	case <-time.After(time.Second):
+		t.Fatal("no event after running again")
+	}
+}
+
+func TestRunCtxClosed(t *testing.T) {
+	sub := ipc.SubscribeCustom(ipc.NewClient(test.NewMockConnection(t), binary.LittleEndian))
+	require.Nil(t, sub.Close())
+	assert.ErrorIs(t, sub.RunCtx(context.Background()), ipc.ErrSubscriptionClosed)
+}
+
+func TestRunCtxDrain(t *testing.T) {
+	tests := map[string]struct {
+		delivery ipc.Delivery
+		drain    time.Duration
+		pending  int
+	}{
+		"Concurrent": {ipc.Delivery{}, time.Second, 0},
+		"Ordered":    {ipc.Delivery{Mode: ipc.OrderedDelivery, Buffer: 4}, time.Second, 0},
+		"Timeout":    {ipc.Delivery{}, 10 * time.Millisecond, 1},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := swaytest.NewServer(t)
+			client, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+			require.Nil(t, err)
+			sub := ipc.SubscribeCustom(client)
+			sub.SetDelivery(tc.delivery)
+			sub.SetDrainTimeout(tc.drain)
+
+			started := make(chan struct{}, 1)
+			release := make(chan struct{})
+			finished := make(chan struct{})
+			_, err = sub.Ticks(func(ipc.Tick) {
+				started <- struct{}{}
+				<-release
+				close(finished)
+			})
+			require.Nil(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- sub.RunCtx(ctx) }()
+
+			server.Emit(ipc.TickEvent, ipc.Tick{})
+			<-started
+			cancel()
+
+			if tc.pending == 0 {
+				select {
+				case <-done:
+					t.Fatal("RunCtx returned before the handler finished")
+				case <-time.After(20 * time.Millisecond):
+				}
+				close(release)
+				<-finished
+			}
+
+			var runErr *ipc.RunError
+			require.ErrorAs(t, <-done, &runErr)
+			assert.Equal(t, tc.pending, runErr.Pending)
+
+			if tc.pending > 0 {
+				close(release)
+			}
+			sub.Close()
+		})
+	}
+}
+
+func TestEvents(t *testing.T) {
+	server := swaytest.NewServer(t)
+	client, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+	require.Nil(t, err)
+	sub := ipc.SubscribeCustom(client)
+	defer sub.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := sub.Events(ctx, ipc.WindowEvent, ipc.TickEvent)
+	require.Nil(t, err)
+	all, err := sub.Events(context.Background())
+	require.Nil(t, err)
+	go sub.Run()
+
+	for i := 1; i <= 3; i++ {
+		server.Emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.NewWindow, Container: ipc.Node{ID: i}})
+	}
+	server.Emit(ipc.WorkspaceEvent, ipc.WorkspaceChange{Change: ipc.InitWorkspace})
+	server.Emit(ipc.TickEvent, ipc.Tick{Payload: "done"})
+
+	for i := 1; i <= 3; i++ {
+		evt := <-events
+		require.Equal(t, ipc.WindowEvent, evt.Type)
+		assert.Equal(t, i, evt.Window.Container.ID)
+		assert.Equal(t, evt.Window, evt.Args())
+	}
+
+	evt := <-events
+	require.Equal(t, ipc.TickEvent, evt.Type)
+	assert.Equal(t, "done", evt.Tick.Payload)
+
+	var types []ipc.EventPayloadType
+	var seq uint64
+	for i := 0; i < 5; i++ {
+		evt := <-all
+		assert.Greater(t, evt.Envelope.Seq, seq)
+		seq = evt.Envelope.Seq
+		types = append(types, evt.Type)
+	}
+	assert.Equal(t, []ipc.EventPayloadType{ipc.WindowEvent, ipc.WindowEvent, ipc.WindowEvent, ipc.WorkspaceEvent, ipc.TickEvent}, types)
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("events channel was not closed after cancel")
+	}
+
+	sub.Close()
+	_, ok := <-all
+	assert.False(t, ok)
+}
+
+func TestEventsSlowReceiver(t *testing.T) {
+	server := swaytest.NewServer(t)
+	client, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+	require.Nil(t, err)
+	sub := ipc.SubscribeCustom(client)
+	defer sub.Close()
+
+	slowctx, release := context.WithCancel(context.Background())
+	defer release()
+	_, err = sub.Events(slowctx, ipc.WindowEvent)
+	require.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := sub.Events(ctx, ipc.WindowEvent)
+	require.Nil(t, err)
+	go sub.Run()
+
+	for i := 1; i <= 20; i++ {
+		server.Emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.NewWindow, Container: ipc.Node{ID: i}})
+	}
+
+	// the slow channel fills up and Run waits for it
+	for i := 0; i < 16; i++ {
+		<-events
+	}
+
+	cancel()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("events channel was not closed while Run waited on another channel")
+		}
+	}
+}
+
+func TestEventsClosedSubscription(t *testing.T) {
+	sub := ipc.SubscribeCustom(nil)
+	_, err := sub.Events(context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestOrderedDelivery(t *testing.T) {
+	tests := map[string]struct {
+		delivery ipc.Delivery
+		expected []int
+	}{
+		"block":       {ipc.Delivery{Mode: ipc.OrderedDelivery, Buffer: 2, Policy: ipc.BlockPolicy}, []int{1, 2, 3, 4, 5}},
+		"drop oldest": {ipc.Delivery{Mode: ipc.OrderedDelivery, Buffer: 2, Policy: ipc.DropOldestPolicy}, []int{1, 4, 5}},
+		"coalesce":    {ipc.Delivery{Mode: ipc.OrderedDelivery, Buffer: 2, Policy: ipc.CoalescePolicy}, []int{1, 5}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := swaytest.NewServer(t)
+			client, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+			require.Nil(t, err)
+			sub := ipc.SubscribeCustom(client)
+			sub.SetDelivery(tc.delivery)
+			defer sub.Close()
+
+			started := make(chan struct{})
+			gate := make(chan struct{})
+			received := make(chan int, 5)
+			_, err = sub.WindowChanges(func(wc ipc.WindowChange) {
+				if wc.Container.ID == 1 {
+					close(started)
+					<-gate
+				}
+				received <- wc.Container.ID
+			})
+			require.Nil(t, err)
+
+			ticks := make(chan struct{})
+			_, err = sub.Ticks(func(ipc.Tick) { close(ticks) })
+			require.Nil(t, err)
+			go sub.Run()
+
+			go func() {
+				server.Emit(ipc.WindowEvent, ipc.WindowChange{Container: ipc.Node{ID: 1}})
+				<-started
+				for i := 2; i <= 5; i++ {
+					server.Emit(ipc.WindowEvent, ipc.WindowChange{Container: ipc.Node{ID: i}})
+				}
+				server.Emit(ipc.TickEvent, ipc.Tick{})
+			}()
+
+			if tc.delivery.Policy != ipc.BlockPolicy {
+				// Run is not held up by the stuck handler
+				<-ticks
+			}
+			close(gate)
+
+			var got []int
+			for range tc.expected {
+				select {
+				case id := <-received:
+					got = append(got, id)
+				case <-time.After(time.Second):
+					t.Fatalf("received %v, expected %v", got, tc.expected)
+				}
+			}
+
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestOrderedDeliveryRemoveHandler(t *testing.T) {
+	server := swaytest.NewServer(t)
+	client, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+	require.Nil(t, err)
+	sub := ipc.SubscribeCustom(client)
+	sub.SetDelivery(ipc.Delivery{Mode: ipc.OrderedDelivery})
+	defer sub.Close()
+
+	var cookie ipc.Cookie
+	received := make(chan int, 10)
+	cookie, err = sub.WindowChanges(func(wc ipc.WindowChange) {
+		received <- wc.Container.ID
+		sub.RemoveHandler(cookie)
+	})
+	require.Nil(t, err)
+	go sub.Run()
+
+	for i := 1; i <= 3; i++ {
+		server.Emit(ipc.WindowEvent, ipc.WindowChange{Container: ipc.Node{ID: i}})
+	}
+
+	assert.Equal(t, 1, <-received)
+	select {
+	case id := <-received:
+		t.Fatalf("removed handler received %v", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestFilteredHandlers(t *testing.T) {
+	server := swaytest.NewServer(t)
+	client, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+	require.Nil(t, err)
+	sub := ipc.SubscribeCustom(client)
+	defer sub.Close()
+
+	errs := make(chan error, 1)
+	sub.Errors(errs)
+
+	windows := make(chan ipc.WindowChange, 10)
+	_, err = sub.WindowChangesFiltered(func(wc ipc.WindowChange) { windows <- wc },
+		func(n *ipc.Node) bool { return n.ID > 1 },
+		ipc.NewWindow, ipc.CloseWindow)
+	require.Nil(t, err)
+
+	workspaces := make(chan ipc.WorkspaceChange, 10)
+	_, err = sub.WorkspaceChangesFiltered(func(wc ipc.WorkspaceChange) { workspaces <- wc }, nil, ipc.InitWorkspace)
+	require.Nil(t, err)
+	go sub.Run()
+
+	// unwanted events are not decoded, so this is not an error
+	server.Emit(ipc.WindowEvent, json.RawMessage(`{"change":"title","container":"not a node"}`))
+	server.Emit(ipc.WindowEvent, json.RawMessage(`{"container":{"change":"new","id":"x"}, "change" : "title"}`))
+	server.Emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.NewWindow, Container: ipc.Node{ID: 1}})
+	server.Emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.FocusWindow, Container: ipc.Node{ID: 2}})
+	server.Emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.CloseWindow, Container: ipc.Node{ID: 3}})
+	server.Emit(ipc.WorkspaceEvent, ipc.WorkspaceChange{Change: ipc.FocusWorkspace})
+	server.Emit(ipc.WorkspaceEvent, ipc.WorkspaceChange{Change: ipc.InitWorkspace})
+
+	wc := <-windows
+	assert.Equal(t, ipc.CloseWindow, wc.Change)
+	assert.Equal(t, 3, wc.Container.ID)
+	assert.Equal(t, ipc.InitWorkspace, (<-workspaces).Change)
+
+	select {
+	case wc := <-windows:
+		t.Fatalf("unexpected window event: %v", wc.Change)
+	case wc := <-workspaces:
+		t.Fatalf("unexpected workspace event: %v", wc.Change)
+	case err := <-errs:
+		t.Fatalf("unexpected error: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestFilterWindowChanges(t *testing.T) {
+	var got []int
+	h := ipc.FilterWindowChanges(func(wc ipc.WindowChange) { got = append(got, wc.Container.ID) },
+		func(n *ipc.Node) bool { return n.ID > 1 },
+		ipc.NewWindow, ipc.CloseWindow)
+
+	h(ipc.WindowChange{Change: ipc.NewWindow, Container: ipc.Node{ID: 1}})
+	h(ipc.WindowChange{Change: ipc.FocusWindow, Container: ipc.Node{ID: 2}})
+	h(ipc.WindowChange{Change: ipc.CloseWindow, Container: ipc.Node{ID: 3}})
+	assert.Equal(t, []int{3}, got)
+}
+
+func TestRawEvents(t *testing.T) {
+	const futureEvent = ipc.EventPayloadType(0x80000099)
+
+	server := swaytest.NewServer(t)
+	client, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+	require.Nil(t, err)
+	sub := ipc.SubscribeCustom(client)
+	defer sub.Close()
+
+	errs := make(chan error, 1)
+	sub.Errors(errs)
+
+	noop := func(ipc.EventPayloadType, json.RawMessage) {}
+	_, err = sub.RawEvents([]ipc.EventPayloadType{ipc.EventPayloadType(0x80000098)}, noop)
+	assert.NotNil(t, err, "unnamed events cannot be subscribed to")
+
+	ipc.RegisterEventName(futureEvent, "future")
+	assert.Equal(t, "future", futureEvent.EventName())
+
+	type raw struct {
+		ept     ipc.EventPayloadType
+		payload string
+	}
+	raws := make(chan raw, 10)
+	_, err = sub.RawEvents([]ipc.EventPayloadType{ipc.WindowEvent, futureEvent}, func(ept ipc.EventPayloadType, payload json.RawMessage) {
+		raws <- raw{ept, string(payload)}
+	})
+	require.Nil(t, err)
+
+	type future struct {
+		Change string `json:"change"`
+	}
+	futures := make(chan future, 10)
+	_, err = ipc.On(sub, futureEvent, func(f future) { futures <- f })
+	require.Nil(t, err)
+	go sub.Run()
+
+	server.Emit(futureEvent, json.RawMessage(`{"change":"soon"}`))
+
+	assert.Equal(t, "soon", (<-futures).Change)
+	r := <-raws
+	assert.Equal(t, futureEvent, r.ept)
+	assert.JSONEq(t, `{"change":"soon"}`, r.payload)
+
+	server.Emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.NewWindow})
+	r = <-raws
+	assert.Equal(t, ipc.WindowEvent, r.ept)
+	assert.Contains(t, r.payload, `"change":"new"`)
+
+	select {
+	case err := <-errs:
+		t.Fatalf("unexpected error: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRegisterWhileRunning(t *testing.T) {
+	server := swaytest.NewServer(t)
+	client, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+	require.Nil(t, err)
+	sub := ipc.SubscribeCustom(client)
+	defer sub.Close()
+
+	errs := make(chan error, 10)
+	sub.Errors(errs)
+
+	windows := make(chan ipc.WindowChange, 10)
+	_, err = sub.WindowChanges(func(wc ipc.WindowChange) { windows <- wc })
+	require.Nil(t, err)
+	go sub.Run()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			server.Emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.NewWindow})
+		}
+	}()
+
+	ticks := make(chan ipc.Tick, 1)
+	_, err = sub.Ticks(func(tick ipc.Tick) { ticks <- tick })
+	require.Nil(t, err)
+	cookie, err := sub.ModeChanges(func(ipc.ModeChange) {})
+	require.Nil(t, err)
+	sub.RemoveHandler(cookie)
+	<-done
+
+	// events sent while switching connections may be lost
+	assert.Eventually(t, func() bool {
+		return server.Subscribers(ipc.ModeEvent) == 0
+	}, time.Second, time.Millisecond)
+	server.WaitForSubscribers(ipc.TickEvent, 1)
+	server.Emit(ipc.TickEvent, ipc.Tick{Payload: "registered"})
+
+	select {
+	case tick := <-ticks:
+		assert.Equal(t, "registered", tick.Payload)
+	case <-time.After(time.Second):
+		t.Fatal("handler registered while running was not called")
+	}
+
+	select {
+	case err := <-errs:
+		t.Fatalf("unexpected error: %s", err)
+	default:
+	}
+}
+
+func TestRemoveLastHandlerUnsubscribes(t *testing.T) {
+	server := swaytest.NewServer(t)
+	client, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+	require.Nil(t, err)
+	sub := ipc.SubscribeCustom(client)
+	defer sub.Close()
+
+	ticks := make(chan ipc.Tick, 1)
+	_, err = sub.Ticks(func(tick ipc.Tick) { ticks <- tick })
+	require.Nil(t, err)
+	first, err := sub.WindowChanges(func(ipc.WindowChange) {})
+	require.Nil(t, err)
+	second, err := sub.WindowChanges(func(ipc.WindowChange) {})
+	require.Nil(t, err)
+	go sub.Run()
+
+	sub.RemoveHandler(first)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 1, server.Subscribers(ipc.WindowEvent))
+
+	sub.RemoveHandler(second)
+	assert.Eventually(t, func() bool {
+		return server.Subscribers(ipc.WindowEvent) == 0
+	}, time.Second, time.Millisecond)
+
+	server.WaitForSubscribers(ipc.TickEvent, 1)
+	server.Emit(ipc.TickEvent, ipc.Tick{Payload: "still here"})
+
+	select {
+	case tick := <-ticks:
+		assert.Equal(t, "still here", tick.Payload)
+	case <-time.After(time.Second):
+		t.Fatal("remaining handler lost its events")
+	}
+}
+
+func TestEnveloped(t *testing.T) {
+	server := swaytest.NewServer(t)
+	client, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+	require.Nil(t, err)
+	sub := ipc.SubscribeCustom(client)
+	sub.SetDelivery(ipc.Delivery{Mode: ipc.OrderedDelivery, Buffer: 8})
+	defer sub.Close()
+
+	windows := make(chan ipc.Event, 3)
+	_, err = sub.Enveloped([]ipc.EventPayloadType{ipc.WindowEvent}, func(evt ipc.Event) { windows <- evt })
+	require.Nil(t, err)
+	all := make(chan ipc.Event, 4)
+	_, err = sub.Enveloped(nil, func(evt ipc.Event) { all <- evt })
+	require.Nil(t, err)
+
+	start := time.Now()
+	go sub.Run()
+
+	server.Emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.NewWindow, Container: ipc.Node{ID: 1}})
+	server.Emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.TitleWindow, Container: ipc.Node{ID: 1, Name: "a longer title"}})
+
+	first, second := <-windows, <-windows
+	require.NotNil(t, first.Window)
+	assert.Equal(t, ipc.NewWindow, first.Window.Change)
+	assert.Equal(t, uint64(1), first.Envelope.Seq)
+	assert.Equal(t, uint64(2), second.Envelope.Seq)
+	assert.False(t, first.Envelope.Received.Before(start))
+	assert.False(t, second.Envelope.Received.Before(first.Envelope.Received))
+	assert.Greater(t, second.Envelope.Size, first.Envelope.Size)
+	assert.GreaterOrEqual(t, first.Envelope.Age(), time.Duration(0))
+
+	assert.Equal(t, first.Envelope, (<-all).Envelope)
+	assert.Equal(t, second.Envelope, (<-all).Envelope)
+}
@@ -0,0 +1,406 @@
+package ipc
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+// errTreeSyntax is returned for a GET_TREE reply that is not valid JSON.
+var errTreeSyntax = errors.New("ipc: invalid tree")
+
+// treeProbe is the part of a container decoded to walk the tree
+// without decoding the rest.
+type treeProbe struct {
+	ID      int
+	Name    string
+	Type    NodeType
+	Focused bool
+	Focus   []int
+	Marks   []string
+}
+
+// member decodes a member of a container into the probe. Unless all is
+// set, only the members needed to follow the focus are decoded.
+func (p *treeProbe) member(key []byte, value []byte, all bool) error {
+	var err error
+	switch string(key) {
+	case "id":
+		p.ID, err = strconv.Atoi(string(value))
+	case "type":
+		p.Type, err = decodeNodeType(value)
+	case "focus":
+		_, err = scanArray(value, 0, func(at int) (int, error) {
+			end, err := skipValue(value, at)
+			if err != nil {
+				return 0, err
+			}
+
+			id, err := strconv.Atoi(string(value[at:end]))
+			p.Focus = append(p.Focus, id)
+			return end, err
+		})
+	}
+
+	if !all || err != nil {
+		return err
+	}
+
+	switch string(key) {
+	case "name":
+		p.Name, err = decodeString(value)
+	case "focused":
+		p.Focused = string(value) == "true"
+	case "marks":
+		_, err = scanArray(value, 0, func(at int) (int, error) {
+			end, err := skipValue(value, at)
+			if err != nil {
+				return 0, err
+			}
+
+			m, err := decodeString(value[at:end])
+			p.Marks = append(p.Marks, m)
+			return end, err
+		})
+	}
+
+	return err
+}
+
+// treeIndex lists the containers of a GET_TREE reply in the order
+// of the reply, each followed by its descendants.
+type treeIndex []treeEntry
+
+type treeEntry struct {
+	treeProbe
+	// raw is the undecoded container, pointing into the reply.
+	raw []byte
+	// next is the index of the entry after the descendants.
+	next int
+}
+
+// index scans a GET_TREE reply once, probing every container.
+// The entries of the last scan are reused.
+func (idx *treeIndex) index(buf []byte, all bool) error {
+	*idx = (*idx)[:0]
+	end, err := idx.scan(buf, skipSpace(buf, 0), all)
+	if err != nil {
+		return err
+	}
+
+	if skipSpace(buf, end) != len(buf) {
+		return errTreeSyntax
+	}
+
+	return nil
+}
+
+// scan adds the container at buf[i] and its descendants,
+// and returns the end of the container.
+func (idx *treeIndex) scan(buf []byte, i int, all bool) (int, error) {
+	n := len(*idx)
+	var p treeProbe
+	if n < cap(*idx) {
+		*idx = (*idx)[:n+1]
+		p.Focus, p.Marks = (*idx)[n].Focus[:0], (*idx)[n].Marks[:0]
+	} else {
+		*idx = append(*idx, treeEntry{})
+	}
+
+	end, err := scanObject(buf, i, func(key []byte, at int) (int, error) {
+		switch string(key) {
+		case "nodes", "floating_nodes":
+			return scanArray(buf, at, func(at int) (int, error) {
+				return idx.scan(buf, at, all)
+			})
+		}
+
+		end, err := skipValue(buf, at)
+		if err != nil {
+			return 0, err
+		}
+
+		return end, p.member(key, buf[at:end], all)
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	(*idx)[n] = treeEntry{p, buf[i:end], len(*idx)}
+	return end, nil
+}
+
+// where decodes the containers in a GET_TREE reply matching pred.
+func (idx *treeIndex) where(buf []byte, pred func(*Node) bool) ([]*Node, error) {
+	if err := idx.index(buf, true); err != nil {
+		return nil, err
+	}
+
+	var matches []*Node
+	var n Node
+	for i := 0; i < len(*idx); {
+		e := &(*idx)[i]
+		n = Node{ID: e.ID, Name: e.Name, Type: e.Type, Focused: e.Focused, Focus: e.Focus, Marks: e.Marks}
+		if !pred(&n) {
+			i++
+			continue
+		}
+
+		match := new(Node)
+		if err := json.Unmarshal(e.raw, match); err != nil {
+			return nil, err
+		}
+
+		matches = append(matches, match)
+		i = e.next
+	}
+
+	return matches, nil
+}
+
+// focusedWorkspace decodes the workspace in a GET_TREE reply that is
+// first in the focus order of its output, which is first in the focus
+// order of the root.
+func (idx *treeIndex) focusedWorkspace(buf []byte) (*Node, error) {
+	if err := idx.index(buf, false); err != nil {
+		return nil, err
+	}
+
+	for i := 0; ; {
+		e := &(*idx)[i]
+		if e.Type == WorkspaceNode {
+			ws := new(Node)
+			if err := json.Unmarshal(e.raw, ws); err != nil {
+				return nil, err
+			}
+
+			return ws, nil
+		}
+
+		if len(e.Focus) == 0 {
+			return nil, nil
+		}
+
+		// the children of e follow it, each followed by its descendants
+		next := -1
+		for c := i + 1; c < e.next; c = (*idx)[c].next {
+			if (*idx)[c].ID == e.Focus[0] {
+				next = c
+				break
+			}
+		}
+
+		if next < 0 {
+			return nil, nil
+		}
+
+		i = next
+	}
+}
+
+func decodeNodeType(value []byte) (NodeType, error) {
+	// the known types without allocating
+	for _, t := range []NodeType{RootNode, OutputNode, WorkspaceNode, ConNode, FloatingConNode} {
+		if len(value) == len(t)+2 && string(value[1:len(value)-1]) == string(t) {
+			return t, nil
+		}
+	}
+
+	s, err := decodeString(value)
+	return NodeType(s), err
+}
+
+// decodeString decodes a string value, or null.
+func decodeString(value []byte) (string, error) {
+	if string(value) == "null" {
+		return "", nil
+	}
+
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return "", errTreeSyntax
+	}
+
+	raw := value[1 : len(value)-1]
+	for _, b := range raw {
+		if b == '\\' {
+			var s string
+			err := json.Unmarshal(value, &s)
+			return s, err
+		}
+	}
+
+	return string(raw), nil
+}
+
+// The funcs below find the members of objects and the elements of
+// arrays in a reply without decoding them. They only check as much
+// of the syntax as they need to, json.Unmarshal checks the rest.
+
+// scanObject calls member with the key of each member of the object
+// at buf[i] and the offset of its value. member returns the end of the
+// value. scanObject returns the end of the object.
+func scanObject(buf []byte, i int, member func(key []byte, at int) (int, error)) (int, error) {
+	if i >= len(buf) || buf[i] != '{' {
+		return 0, errTreeSyntax
+	}
+
+	i = skipSpace(buf, i+1)
+	if i < len(buf) && buf[i] == '}' {
+		return i + 1, nil
+	}
+
+	for {
+		if i >= len(buf) || buf[i] != '"' {
+			return 0, errTreeSyntax
+		}
+
+		end, err := skipString(buf, i)
+		if err != nil {
+			return 0, err
+		}
+
+		key := buf[i+1 : end-1]
+		i = skipSpace(buf, end)
+		if i >= len(buf) || buf[i] != ':' {
+			return 0, errTreeSyntax
+		}
+
+		i, err = member(key, skipSpace(buf, i+1))
+		if err != nil {
+			return 0, err
+		}
+
+		i = skipSpace(buf, i)
+		if i >= len(buf) {
+			return 0, errTreeSyntax
+		}
+
+		switch buf[i] {
+		case '}':
+			return i + 1, nil
+		case ',':
+			i = skipSpace(buf, i+1)
+		default:
+			return 0, errTreeSyntax
+		}
+	}
+}
+
+// scanArray calls elem with the offset of each element of the array
+// at buf[i], or of none for null. elem returns the end of the element.
+// scanArray returns the end of the array.
+func scanArray(buf []byte, i int, elem func(at int) (int, error)) (int, error) {
+	if len(buf)-i >= 4 && string(buf[i:i+4]) == "null" {
+		return i + 4, nil
+	}
+
+	if i >= len(buf) || buf[i] != '[' {
+		return 0, errTreeSyntax
+	}
+
+	i = skipSpace(buf, i+1)
+	if i < len(buf) && buf[i] == ']' {
+		return i + 1, nil
+	}
+
+	for {
+		var err error
+		i, err = elem(i)
+		if err != nil {
+			return 0, err
+		}
+
+		i = skipSpace(buf, i)
+		if i >= len(buf) {
+			return 0, errTreeSyntax
+		}
+
+		switch buf[i] {
+		case ']':
+			return i + 1, nil
+		case ',':
+			i = skipSpace(buf, i+1)
+		default:
+			return 0, errTreeSyntax
+		}
+	}
+}
+
+// skipValue returns the end of the value at buf[i].
+func skipValue(buf []byte, i int) (int, error) {
+	if i >= len(buf) {
+		return 0, errTreeSyntax
+	}
+
+	switch buf[i] {
+	case '"':
+		return skipString(buf, i)
+	case '{', '[':
+		depth := 0
+		for i < len(buf) {
+			switch buf[i] {
+			case '"':
+				end, err := skipString(buf, i)
+				if err != nil {
+					return 0, err
+				}
+
+				i = end
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1, nil
+				}
+			}
+
+			i++
+		}
+
+		return 0, errTreeSyntax
+	}
+
+	start := i
+	for i < len(buf) && !isDelim(buf[i]) {
+		i++
+	}
+
+	if i == start {
+		return 0, errTreeSyntax
+	}
+
+	return i, nil
+}
+
+// skipString returns the end of the string at buf[i].
+func skipString(buf []byte, i int) (int, error) {
+	for i++; i < len(buf); i++ {
+		switch buf[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+
+	return 0, errTreeSyntax
+}
+
+func skipSpace(buf []byte, i int) int {
+	for i < len(buf) && isSpace(buf[i]) {
+		i++
+	}
+
+	return i
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+func isDelim(b byte) bool {
+	return isSpace(b) || b == ',' || b == '}' || b == ']'
+}
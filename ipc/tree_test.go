@@ -0,0 +1,207 @@
+package ipc_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/libanvl/swager/ipc"
+	"github.com/libanvl/swager/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleClient(t *testing.T, payload []byte) *ipc.Client {
+	conn := test.NewMockConnection(t)
+	conn.PushPayloadForRead(uint32(ipc.GetTreeMessage), payload, binary.LittleEndian)
+	return ipc.NewClient(conn, binary.LittleEndian)
+}
+
+func TestTreeWhere(t *testing.T) {
+	buf := loadTree(t)
+	full := new(ipc.Node)
+	require.Nil(t, json.Unmarshal(buf, full))
+	ws := full.Nodes[1].Nodes[0]
+
+	tests := map[string]struct {
+		pred     func(*ipc.Node) bool
+		expected []*ipc.Node
+		seen     []int
+	}{
+		"Views": {
+			func(n *ipc.Node) bool { return n.Type == ipc.ConNode || n.Type == ipc.FloatingConNode },
+			[]*ipc.Node{ws.Nodes[0], ws.Nodes[1], ws.FloatingNodes[0], ws.FloatingNodes[1]},
+			[]int{1, 2147483647, 2147483646, 3, 4, 5, 6, 7, 8},
+		},
+		"Workspace": {
+			func(n *ipc.Node) bool { return n.Name == "1:term" },
+			[]*ipc.Node{ws},
+			[]int{1, 2147483647, 2147483646, 3, 4},
+		},
+		"Mark": {
+			func(n *ipc.Node) bool { return len(n.Marks) > 0 && n.Marks[0] == "main" },
+			[]*ipc.Node{ws.Nodes[0]},
+			[]int{1, 2147483647, 2147483646, 3, 4, 5, 6, 7, 8},
+		},
+		"None": {
+			func(n *ipc.Node) bool { return false },
+			nil,
+			[]int{1, 2147483647, 2147483646, 3, 4, 5, 6, 7, 8},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := sampleClient(t, buf)
+
+			var seen []int
+			actual, err := client.TreeWhere(func(n *ipc.Node) bool {
+				seen = append(seen, n.ID)
+				assert.Nil(t, n.Nodes)
+				assert.Nil(t, n.FloatingNodes)
+				return tc.pred(n)
+			})
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.seen, seen)
+		})
+	}
+}
+
+func TestFocusedWorkspace(t *testing.T) {
+	buf := loadTree(t)
+	full := new(ipc.Node)
+	require.Nil(t, json.Unmarshal(buf, full))
+
+	ws, err := sampleClient(t, buf).FocusedWorkspace()
+	assert.Nil(t, err)
+	assert.Equal(t, full.Nodes[1].Nodes[0], ws)
+
+	ws, err = sampleClient(t, []byte(`{"id":1,"type":"root","focus":[],"nodes":[]}`)).FocusedWorkspace()
+	assert.Nil(t, err)
+	assert.Nil(t, ws)
+
+	_, err = sampleClient(t, []byte(`{"id":1,"type":"root","focus":[2],"nodes":[{"id":`)).FocusedWorkspace()
+	assert.NotNil(t, err)
+}
+
+func TestReusedReplyBuffer(t *testing.T) {
+	conn := test.NewMockConnection(t)
+	client := ipc.NewClient(conn, binary.LittleEndian)
+	conn.PushPayloadForRead(uint32(ipc.GetTreeMessage), []byte(`{"id":1,"name":"first","nodes":[]}`), binary.LittleEndian)
+	conn.PushPayloadForRead(uint32(ipc.GetTreeMessage), []byte(`{"id":2,"name":"other","nodes":[]}`), binary.LittleEndian)
+	conn.PushPayloadForRead(uint32(ipc.GetMarksMessage), []byte(`["mark"]`), binary.LittleEndian)
+
+	all := func(*ipc.Node) bool { return true }
+	first, err := client.TreeWhere(all)
+	require.Nil(t, err)
+	second, err := client.TreeWhere(all)
+	require.Nil(t, err)
+	marks, err := client.Marks()
+	require.Nil(t, err)
+
+	require.Len(t, first, 1)
+	require.Len(t, second, 1)
+	assert.Equal(t, "first", first[0].Name)
+	assert.Equal(t, "other", second[0].Name)
+	assert.Equal(t, []string{"mark"}, marks)
+}
+
+// replayConn answers every message with the same reply.
+type replayConn struct {
+	reply []byte
+	r     bytes.Reader
+}
+
+func newReplayConn(pt ipc.PayloadType, payload []byte) *replayConn {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, ipc.NewHeader(pt, len(payload)))
+	buf.Write(payload)
+	return &replayConn{reply: buf.Bytes()}
+}
+
+func (c *replayConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func (c *replayConn) Write(p []byte) (int, error) {
+	c.r.Reset(c.reply)
+	return len(p), nil
+}
+
+func (c *replayConn) Close() error {
+	return nil
+}
+
+// largeTree returns a tree of workspaces full of copies
+// of the views in the sample tree.
+func largeTree(b *testing.B, workspaces int, views int) []byte {
+	buf, err := os.ReadFile("testdata/get_tree.json")
+	require.Nil(b, err)
+
+	root := new(ipc.Node)
+	require.Nil(b, json.Unmarshal(buf, root))
+
+	output := root.Nodes[1]
+	template := output.Nodes[0]
+	output.Nodes, output.Focus = nil, nil
+
+	id := 100
+	for w := 0; w < workspaces; w++ {
+		ws := *template
+		ws.ID, ws.Name = id, strconv.Itoa(w+1)
+		ws.Nodes, ws.FloatingNodes, ws.Focus = nil, nil, nil
+		id++
+
+		for v := 0; v < views; v++ {
+			view := *template.Nodes[v%len(template.Nodes)]
+			view.ID = id
+			view.Focused = w == 0 && v == 0
+			id++
+			ws.Nodes = append(ws.Nodes, &view)
+			ws.Focus = append(ws.Focus, view.ID)
+		}
+
+		output.Nodes = append(output.Nodes, &ws)
+		output.Focus = append(output.Focus, ws.ID)
+	}
+
+	payload, err := json.Marshal(root)
+	require.Nil(b, err)
+	return payload
+}
+
+func BenchmarkTreeDecode(b *testing.B) {
+	payload := largeTree(b, 10, 20)
+	benchmarks := map[string]func(*ipc.Client) error{
+		"Tree": func(c *ipc.Client) error {
+			_, err := c.Tree()
+			return err
+		},
+		"TreeWhere": func(c *ipc.Client) error {
+			_, err := c.TreeWhere(func(n *ipc.Node) bool { return n.Focused })
+			return err
+		},
+		"FocusedWorkspace": func(c *ipc.Client) error {
+			_, err := c.FocusedWorkspace()
+			return err
+		},
+	}
+
+	for name, bm := range benchmarks {
+		b.Run(name, func(b *testing.B) {
+			client := ipc.NewClient(newReplayConn(ipc.GetTreeMessage, payload), binary.LittleEndian)
+			b.SetBytes(int64(len(payload)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := bm(client); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
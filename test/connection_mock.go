@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"io"
+	"sync"
 	"testing"
 
 	"github.com/libanvl/swager/ipc"
@@ -15,6 +16,10 @@ func init() {
 }
 
 type MockConnection struct {
+	// EOFWhenEmpty makes Read fail with io.EOF once all pushed reads
+	// are consumed, like a peer that hung up.
+	EOFWhenEmpty    bool
+	mx              sync.Mutex
 	t               *testing.T
 	log             bool
 	callCounts      map[string]uint
@@ -40,6 +45,9 @@ func (mc *MockConnection) Logf(msg string, args ...any) {
 
 // Read implements io.ReadWriteCloser
 func (mc *MockConnection) Read(p []byte) (n int, err error) {
+	mc.mx.Lock()
+	defer mc.mx.Unlock()
+
 	mc.callCounts["Read"]++
 
 	size := len(mc.nextRead)
@@ -58,12 +66,20 @@ func (mc *MockConnection) Read(p []byte) (n int, err error) {
 		}
 	}
 
+	if mc.EOFWhenEmpty {
+		mc.Logf("Read: 0, EOF")
+		return 0, io.EOF
+	}
+
 	mc.Logf("Read: 1, nil")
 	return 1, nil
 }
 
 // Write implements io.ReadWriteCloser
 func (mc *MockConnection) Write(p []byte) (n int, err error) {
+	mc.mx.Lock()
+	defer mc.mx.Unlock()
+
 	mc.callCounts["Write"]++
 	if mc.writes == nil {
 		mc.writes = make([][]byte, 0)
@@ -82,11 +98,17 @@ func (mc *MockConnection) Write(p []byte) (n int, err error) {
 
 // Close implements io.ReadWriteCloser
 func (mc *MockConnection) Close() error {
+	mc.mx.Lock()
+	defer mc.mx.Unlock()
+
 	mc.callCounts["Close"]++
 	return nil
 }
 
 func (mc *MockConnection) WriteAt(n int) []byte {
+	mc.mx.Lock()
+	defer mc.mx.Unlock()
+
 	assert.NotNil(mc.t, mc.writes)
 	value := mc.writes[n]
 	assert.NotNil(mc.t, value)
@@ -95,6 +117,9 @@ func (mc *MockConnection) WriteAt(n int) []byte {
 }
 
 func (mc *MockConnection) SetNextWriteResult(n int, err error) {
+	mc.mx.Lock()
+	defer mc.mx.Unlock()
+
 	mc.nextWriteResult = &struct {
 		n int
 		e error
@@ -102,6 +127,9 @@ func (mc *MockConnection) SetNextWriteResult(n int, err error) {
 }
 
 func (mc *MockConnection) PushNextReadBytes(p []byte) {
+	mc.mx.Lock()
+	defer mc.mx.Unlock()
+
 	if mc.nextRead == nil {
 		mc.nextRead = make([]ReadValue, 0)
 	}
@@ -110,6 +138,9 @@ func (mc *MockConnection) PushNextReadBytes(p []byte) {
 }
 
 func (mc *MockConnection) PushNextReadError(err error) {
+	mc.mx.Lock()
+	defer mc.mx.Unlock()
+
 	if mc.nextRead == nil {
 		mc.nextRead = make([]ReadValue, 0)
 	}
@@ -129,13 +160,22 @@ func (mc *MockConnection) PushPayloadForRead(payloadType uint32, p []byte, yo bi
 }
 
 func (mc *MockConnection) AssertCalled(method string) bool {
+	mc.mx.Lock()
+	defer mc.mx.Unlock()
+
 	return assert.True(mc.t, mc.callCounts[method] >= 1)
 }
 
 func (mc *MockConnection) AssertNotCalled(method string) bool {
+	mc.mx.Lock()
+	defer mc.mx.Unlock()
+
 	return assert.Zero(mc.t, mc.callCounts[method])
 }
 
 func (mc *MockConnection) AssertNumberOfCalls(method string, calls uint) bool {
+	mc.mx.Lock()
+	defer mc.mx.Unlock()
+
 	return assert.Equal(mc.t, calls, mc.callCounts[method])
 }
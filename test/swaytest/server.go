@@ -0,0 +1,351 @@
+/*
+Package swaytest provides an in-process fake of the sway-ipc socket.
+
+A Server listens on a temporary Unix socket and speaks the i3-ipc framing,
+so ipc.Connect, ipc.Subscribe and everything built on them can be tested
+end to end by pointing SWAYSOCK at it.
+
+Replies are canned per PayloadType with Reply, or computed with Handle.
+Messages without a configured reply get a default: RUN_COMMAND succeeds
+for every sub-command, SUBSCRIBE succeeds and registers the connection
+for the events, and queries return empty values. Events are pushed to the
+subscribed connections with Emit. Unlike sway, the Server does not greet
+new tick subscribers with a first tick event.
+
+For tests of code that reacts to the layout, Simulate backs the Server with
+a Tree, an in-memory model of outputs, workspaces and containers that
+applies commands and emits the matching window and workspace events.
+*/
+package swaytest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libanvl/swager/ipc"
+)
+
+// Handler computes the reply to a message from its payload.
+// The returned value is encoded as json, unless it is a []byte
+// or json.RawMessage, which is sent as is.
+type Handler func(payload []byte) any
+
+// Message is a message received by the Server.
+type Message struct {
+	Type    ipc.PayloadType
+	Payload []byte
+}
+
+type Server struct {
+	t        testing.TB
+	path     string
+	yo       binary.ByteOrder
+	listener net.Listener
+	mx       sync.Mutex
+	handlers map[ipc.PayloadType]Handler
+	received []Message
+	conns    map[*conn]struct{}
+	wg       sync.WaitGroup
+}
+
+type conn struct {
+	net.Conn
+	writemx sync.Mutex
+	events  map[string]bool
+}
+
+// NewServer starts a Server on a socket in a temporary directory.
+// The Server is closed when the test completes.
+func NewServer(t testing.TB) *Server {
+	path := t.TempDir() + "/sway-ipc.sock"
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("swaytest: listen: %v", err)
+	}
+
+	s := &Server{
+		t:        t,
+		path:     path,
+		yo:       binary.LittleEndian,
+		listener: l,
+		handlers: make(map[ipc.PayloadType]Handler),
+		conns:    make(map[*conn]struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.accept()
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Path returns the path of the Unix socket.
+func (s *Server) Path() string {
+	return s.path
+}
+
+// Setenv points SWAYSOCK at the Server for the duration of the test.
+func (s *Server) Setenv() {
+	s.t.Setenv("SWAYSOCK", s.path)
+}
+
+// Reply sets a canned reply for every message of type pt.
+func (s *Server) Reply(pt ipc.PayloadType, v any) {
+	s.Handle(pt, func([]byte) any { return v })
+}
+
+// Handle sets the Handler for every message of type pt.
+func (s *Server) Handle(pt ipc.PayloadType, h Handler) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.handlers[pt] = h
+}
+
+// Simulate serves GET_TREE, GET_WORKSPACES, GET_MARKS and RUN_COMMAND
+// from tree, and emits the events caused by changes to tree.
+func (s *Server) Simulate(tree *Tree) {
+	s.Handle(ipc.GetTreeMessage, func([]byte) any { return tree.Root() })
+	s.Handle(ipc.GetWorkspacesMessage, func([]byte) any { return tree.Workspaces() })
+	s.Handle(ipc.GetMarksMessage, func([]byte) any { return tree.Marks() })
+	s.Handle(ipc.RunCommandMessage, func(payload []byte) any { return tree.Apply(string(payload)) })
+	tree.OnEvent(s.Emit)
+}
+
+// Received returns every message received so far, in order.
+func (s *Server) Received() []Message {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return append([]Message(nil), s.received...)
+}
+
+// Commands returns the payload of every RUN_COMMAND message received so far.
+func (s *Server) Commands() []string {
+	var cmds []string
+	for _, m := range s.Received() {
+		if m.Type == ipc.RunCommandMessage {
+			cmds = append(cmds, string(m.Payload))
+		}
+	}
+
+	return cmds
+}
+
+// Subscribers returns the number of connections subscribed to ept.
+func (s *Server) Subscribers(ept ipc.EventPayloadType) int {
+	name := ept.EventName()
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	n := 0
+	for c := range s.conns {
+		if c.events[name] {
+			n++
+		}
+	}
+
+	return n
+}
+
+// WaitForSubscribers waits until at least n connections
+// are subscribed to ept, failing the test after five seconds.
+func (s *Server) WaitForSubscribers(ept ipc.EventPayloadType, n int) {
+	s.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Subscribers(ept) < n {
+		if time.Now().After(deadline) {
+			s.t.Fatalf("swaytest: timed out waiting for %d %v subscribers", n, ept)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Emit sends an event to every connection subscribed to ept.
+// v is encoded like the result of a Handler.
+func (s *Server) Emit(ept ipc.EventPayloadType, v any) {
+	payload, err := encode(v)
+	if err != nil {
+		s.t.Fatalf("swaytest: encode %v: %v", ept, err)
+	}
+
+	name := ept.EventName()
+	s.mx.Lock()
+	var subscribed []*conn
+	for c := range s.conns {
+		if c.events[name] {
+			subscribed = append(subscribed, c)
+		}
+	}
+	s.mx.Unlock()
+
+	for _, c := range subscribed {
+		s.send(c, ipc.PayloadType(ept), payload)
+	}
+}
+
+// Close stops the Server and closes all connections.
+func (s *Server) Close() {
+	s.listener.Close()
+
+	s.mx.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mx.Unlock()
+
+	s.wg.Wait()
+}
+
+// Disconnect closes all open connections, as if sway went away,
+// while still accepting new ones.
+func (s *Server) Disconnect() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for c := range s.conns {
+		c.Close()
+		delete(s.conns, c)
+	}
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &conn{Conn: nc, events: make(map[string]bool)}
+		s.mx.Lock()
+		s.conns[c] = struct{}{}
+		s.mx.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(c)
+	}
+}
+
+func (s *Server) serve(c *conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mx.Lock()
+		delete(s.conns, c)
+		s.mx.Unlock()
+		c.Close()
+	}()
+
+	for {
+		var h ipc.Header
+		if err := binary.Read(c, s.yo, &h); err != nil {
+			return
+		}
+
+		if !ipc.ValidMagic(h.Magic) {
+			return
+		}
+
+		payload := make([]byte, int(h.PayloadLength))
+		if _, err := io.ReadFull(c, payload); err != nil {
+			return
+		}
+
+		s.mx.Lock()
+		s.received = append(s.received, Message{h.PayloadType, payload})
+		handler, ok := s.handlers[h.PayloadType]
+		s.mx.Unlock()
+
+		var reply any
+		if ok {
+			reply = handler(payload)
+		} else {
+			reply = s.defaultReply(c, h.PayloadType, payload)
+		}
+
+		out, err := encode(reply)
+		if err != nil {
+			s.t.Errorf("swaytest: encode reply to %v: %v", h.PayloadType, err)
+			return
+		}
+
+		if err := s.send(c, h.PayloadType, out); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) defaultReply(c *conn, pt ipc.PayloadType, payload []byte) any {
+	success := ipc.Result{Success: true}
+
+	switch pt {
+	case ipc.RunCommandMessage:
+		cmds := ipc.SplitCommand(string(payload))
+		res := make([]ipc.Command, len(cmds))
+		for i := range res {
+			res[i].Success = true
+		}
+		return res
+	case ipc.SubscribeMessage:
+		var names []string
+		if err := json.Unmarshal(payload, &names); err != nil {
+			return ipc.Result{Success: false}
+		}
+
+		s.mx.Lock()
+		for _, n := range names {
+			c.events[n] = true
+		}
+		s.mx.Unlock()
+		return success
+	case ipc.SendTickMessage:
+		go s.Emit(ipc.TickEvent, ipc.Tick{Payload: string(payload)})
+		return success
+	case ipc.SyncMessage:
+		// sway has no i3 sync to take part in, so it always fails
+		return ipc.Result{Success: false}
+	case ipc.GetTreeMessage:
+		return ipc.Node{ID: 1, Name: "root", Type: ipc.RootNode}
+	case ipc.GetVersionMessage:
+		return ipc.Version{Major: 1, HumanReadable: "swaytest"}
+	case ipc.GetConfigMessage:
+		return ipc.Config{}
+	case ipc.GetBindingStateMessage:
+		return ipc.BindingState{Name: "default"}
+	case ipc.GetBarConfigMessage:
+		if len(payload) > 0 {
+			return ipc.BarConfig{ID: string(payload)}
+		}
+	}
+
+	return []any{}
+}
+
+func (s *Server) send(c *conn, pt ipc.PayloadType, payload []byte) error {
+	c.writemx.Lock()
+	defer c.writemx.Unlock()
+
+	if err := binary.Write(c, s.yo, ipc.NewHeader(pt, len(payload))); err != nil {
+		return err
+	}
+
+	_, err := c.Write(payload)
+	return err
+}
+
+func encode(v any) ([]byte, error) {
+	switch p := v.(type) {
+	case []byte:
+		return p, nil
+	case json.RawMessage:
+		return p, nil
+	case nil:
+		return nil, errors.New("nil reply")
+	}
+
+	return json.Marshal(v)
+}
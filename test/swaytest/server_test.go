@@ -0,0 +1,85 @@
+package swaytest_test
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/libanvl/swager/ipc"
+	"github.com/libanvl/swager/test/swaytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerDefaults(t *testing.T) {
+	server := swaytest.NewServer(t)
+	server.Setenv()
+
+	client, err := ipc.Connect()
+	require.Nil(t, err)
+	defer client.Close()
+
+	res, err := client.Command("focus parent; splitv, focus child")
+	require.Nil(t, err)
+	assert.Len(t, res, 3)
+
+	ws, err := client.Workspaces()
+	assert.Nil(t, err)
+	assert.Empty(t, ws)
+
+	tree, err := client.Tree()
+	require.Nil(t, err)
+	assert.Equal(t, ipc.RootNode, tree.Type)
+
+	sync, err := client.Sync()
+	require.Nil(t, err)
+	assert.False(t, sync.Success)
+
+	assert.Equal(t, []string{"focus parent; splitv, focus child"}, server.Commands())
+	assert.Len(t, server.Received(), 4)
+}
+
+func TestServerReplies(t *testing.T) {
+	server := swaytest.NewServer(t)
+	server.Reply(ipc.GetWorkspacesMessage, []ipc.Workspace{{Num: 1, Name: "1", Focused: true}})
+	server.Handle(ipc.RunCommandMessage, func(payload []byte) any {
+		return []ipc.Command{{Error: "no " + string(payload)}}
+	})
+
+	client, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+	require.Nil(t, err)
+	defer client.Close()
+
+	ws, err := client.Workspaces()
+	require.Nil(t, err)
+	assert.Equal(t, "1", ws[0].Name)
+
+	err = client.CommandChecked("kill")
+	assert.ErrorContains(t, err, "no kill")
+}
+
+func TestServerEmit(t *testing.T) {
+	server := swaytest.NewServer(t)
+	server.Setenv()
+
+	sub, err := ipc.Subscribe()
+	require.Nil(t, err)
+	defer sub.Close()
+
+	windows := make(chan ipc.WindowChange, 1)
+	_, err = sub.WindowChanges(func(wc ipc.WindowChange) { windows <- wc })
+	require.Nil(t, err)
+	go sub.Run()
+
+	server.WaitForSubscribers(ipc.WindowEvent, 1)
+	assert.Zero(t, server.Subscribers(ipc.WorkspaceEvent))
+	server.Emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.NewWindow, Container: ipc.Node{ID: 7}})
+
+	select {
+	case wc := <-windows:
+		assert.Equal(t, ipc.NewWindow, wc.Change)
+		assert.Equal(t, 7, wc.Container.ID)
+	case <-time.After(time.Second):
+		t.Fatal("event was not delivered")
+	}
+}
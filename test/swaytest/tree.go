@@ -0,0 +1,1024 @@
+package swaytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/libanvl/swager/ipc"
+	"github.com/libanvl/swager/ipc/command"
+)
+
+// leafLayout is the layout sway reports for containers holding a window.
+const leafLayout ipc.LayoutType = "none"
+
+// Tree is an in-memory model of the sway tree: outputs, workspaces,
+// containers and focus. It applies a useful subset of sway commands and
+// reports the window and workspace events sway would send.
+//
+// Geometry is not modelled; every Rect is zero. Floating containers,
+// fullscreen and the scratchpad are not modelled either.
+type Tree struct {
+	mx      sync.Mutex
+	root    *ipc.Node
+	nextID  int
+	focused *ipc.Node
+	pending []event
+	onEvent func(ipc.EventPayloadType, any)
+
+	// ExecHook is called with the command line of every exec command,
+	// after the command was applied. It can call OpenWindow to simulate
+	// the launched program.
+	ExecHook func(cmdline string)
+}
+
+type event struct {
+	ept  ipc.EventPayloadType
+	args any
+}
+
+// NewTree returns a Tree with one output for each name,
+// each showing a workspace named after its position, starting at "1".
+// The workspace of the first output is focused.
+func NewTree(outputs ...string) *Tree {
+	t := &Tree{nextID: 1}
+	t.root = t.newNode(ipc.RootNode, "root")
+	t.root.Layout = ipc.SplitHLayout
+
+	for i, name := range outputs {
+		output := t.newNode(ipc.OutputNode, name)
+		output.Layout = ipc.OutputLayout
+		t.add(t.root, output, -1)
+		ws := t.newWorkspace(strconv.Itoa(i + 1))
+		t.add(output, ws, -1)
+		if t.focused == nil {
+			t.focus(ws)
+		}
+	}
+
+	t.pending = nil
+	return t
+}
+
+// OnEvent sets the func that receives the events caused by changes
+// to the Tree. It is called after the change is complete.
+func (t *Tree) OnEvent(f func(ipc.EventPayloadType, any)) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.onEvent = f
+}
+
+// Root returns a deep copy of the tree.
+func (t *Tree) Root() *ipc.Node {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return snapshot(t.root)
+}
+
+// Focused returns a deep copy of the focused node.
+func (t *Tree) Focused() *ipc.Node {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return snapshot(t.focused)
+}
+
+// Workspaces returns the workspaces like GET_WORKSPACES.
+func (t *Tree) Workspaces() []ipc.Workspace {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	focusedws := t.workspaceOf(t.focused)
+	var wss []ipc.Workspace
+	for _, output := range t.root.Nodes {
+		for _, ws := range output.Nodes {
+			wss = append(wss, ipc.Workspace{
+				Num:     *ws.Num,
+				Name:    ws.Name,
+				Visible: len(output.Focus) > 0 && output.Focus[0] == ws.ID,
+				Focused: ws == focusedws,
+				Output:  *ws.Output,
+			})
+		}
+	}
+
+	return wss
+}
+
+// Marks returns every mark in the tree like GET_MARKS.
+func (t *Tree) Marks() []string {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	marks := []string{}
+	t.walk(t.root, func(n *ipc.Node) {
+		marks = append(marks, n.Marks...)
+	})
+
+	return marks
+}
+
+// Shape renders the containers of the named workspace in the notation
+// sway uses for the representation of a container, such as H[a V[b c]].
+// Windows are named by their app_id. Focus is not part of the shape.
+func (t *Tree) Shape(workspace string) string {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	ws := t.findWorkspace(workspace)
+	if ws == nil {
+		return ""
+	}
+
+	return shape(ws)
+}
+
+// OpenWindow opens a window with the given app_id next to the
+// focused container and focuses it, like sway does for a new window.
+// It returns the con_id of the window, or 0 when the Tree has no
+// outputs to open it on.
+func (t *Tree) OpenWindow(appID string) int {
+	t.mx.Lock()
+	id := t.openWindow(appID)
+	events := t.flush()
+	t.mx.Unlock()
+
+	t.dispatch(events)
+	return id
+}
+
+// CloseWindow closes the window with the given con_id.
+func (t *Tree) CloseWindow(id int) error {
+	t.mx.Lock()
+	n := t.byID(id)
+	if n == nil || !isWindow(n) {
+		t.mx.Unlock()
+		return fmt.Errorf("no window with con_id %d", id)
+	}
+
+	t.close(n)
+	events := t.flush()
+	t.mx.Unlock()
+
+	t.dispatch(events)
+	return nil
+}
+
+// Apply runs a RUN_COMMAND payload against the Tree and
+// returns one result per sub-command, like sway.
+//
+// Supported commands are split, focus, layout, move, workspace, mark,
+// unmark, kill, exec and nop. Criteria can match on any field of
+// command.Criteria.
+func (t *Tree) Apply(cmd string) []ipc.Command {
+	t.mx.Lock()
+	var results []ipc.Command
+	var execs []string
+
+chain:
+	for _, group := range ipc.SplitCommandGroups(cmd) {
+		// criteria apply until the next ';'
+		var targets []*ipc.Node
+		for _, text := range group {
+			if strings.HasPrefix(text, "[") {
+				criteria, rest, ok := ipc.SplitCriteria(text)
+				if !ok {
+					results = append(results, parseError(text))
+					break chain
+				}
+
+				var err error
+				targets, err = t.match(criteria)
+				if err != nil {
+					results = append(results, ipc.Command{Error: err.Error()})
+					break chain
+				}
+
+				text = rest
+			}
+
+			args := fields(text)
+			if len(args) == 0 {
+				results = append(results, parseError(text))
+				break chain
+			}
+
+			if args[0] == "exec" {
+				execs = append(execs, strings.TrimSpace(strings.TrimPrefix(text, "exec")))
+				results = append(results, success())
+				continue
+			}
+
+			res := t.run(args, targets)
+			results = append(results, res)
+			if res.ParseError {
+				break chain
+			}
+		}
+	}
+
+	events := t.flush()
+	hook := t.ExecHook
+	t.mx.Unlock()
+
+	t.dispatch(events)
+	if hook != nil {
+		for _, e := range execs {
+			hook(e)
+		}
+	}
+
+	return results
+}
+
+func (t *Tree) run(args []string, targets []*ipc.Node) ipc.Command {
+	if targets == nil {
+		targets = []*ipc.Node{t.focused}
+	} else if len(targets) == 0 {
+		return ipc.Command{Error: "No matching node."}
+	}
+
+	for _, target := range targets {
+		if res := t.runOn(args, target); !res.Success {
+			return res
+		}
+	}
+
+	return success()
+}
+
+func (t *Tree) runOn(args []string, target *ipc.Node) ipc.Command {
+	name, args := args[0], args[1:]
+	arg := func(i int) string {
+		if i < len(args) {
+			return args[i]
+		}
+		return ""
+	}
+
+	switch name {
+	case "nop":
+		return success()
+	case "splith", "splitv", "splitt":
+		t.split(target, name[len(name)-1:])
+		return success()
+	case "split":
+		switch arg(0) {
+		case "h", "horizontal":
+			t.split(target, "h")
+		case "v", "vertical":
+			t.split(target, "v")
+		case "t", "toggle":
+			t.split(target, "t")
+		default:
+			return usage("split <h|v|t>")
+		}
+		return success()
+	case "focus":
+		return t.focusCommand(target, arg(0))
+	case "layout":
+		return t.layoutCommand(target, args)
+	case "move":
+		return t.moveCommand(target, args)
+	case "workspace":
+		if arg(0) == "number" {
+			return t.switchWorkspace(arg(1))
+		}
+		return t.switchWorkspace(arg(0))
+	case "mark":
+		return t.mark(target, args)
+	case "unmark":
+		t.walk(t.root, func(n *ipc.Node) {
+			n.Marks = remove(n.Marks, arg(0))
+		})
+		return success()
+	case "kill":
+		if !isWindow(target) {
+			return ipc.Command{Error: "Can only kill windows in swaytest"}
+		}
+		t.close(target)
+		return success()
+	case "floating", "fullscreen", "sticky", "resize", "swap", "scratchpad", "reload", "exit":
+		return ipc.Command{Error: fmt.Sprintf("'%s' is not supported by swaytest", name)}
+	}
+
+	return parseError(name)
+}
+
+func (t *Tree) openWindow(appID string) int {
+	if t.focused == nil {
+		return 0
+	}
+
+	con := t.newNode(ipc.ConNode, appID)
+	con.AppID = &appID
+	con.Layout = leafLayout
+	con.Orientation = ipc.NoneOrientation
+	visible := true
+	con.Visible = &visible
+
+	target := t.focused
+	if target.Type == ipc.WorkspaceNode {
+		t.add(target, con, -1)
+	} else {
+		parent := t.parent(target)
+		t.add(parent, con, indexOf(parent.Nodes, target)+1)
+	}
+
+	t.emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.NewWindow, Container: *snapshot(con)})
+	t.focusWindow(con)
+	return con.ID
+}
+
+func (t *Tree) close(n *ipc.Node) {
+	wasFocused := t.focused == n || t.isAncestor(n, t.focused)
+	parent := t.unlink(n)
+	t.emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.CloseWindow, Container: *snapshot(n)})
+
+	if wasFocused {
+		t.refocus(parent)
+	}
+
+	t.considerDestroy(t.workspaceOf(parent))
+}
+
+// unlink detaches n, and any container left empty by that,
+// from the tree. It returns the closest remaining ancestor.
+func (t *Tree) unlink(n *ipc.Node) *ipc.Node {
+	parent := t.parent(n)
+	t.detach(n)
+
+	for parent.Type == ipc.ConNode && len(parent.Nodes) == 0 {
+		grandparent := t.parent(parent)
+		t.detach(parent)
+		parent = grandparent
+	}
+
+	return parent
+}
+
+func (t *Tree) split(target *ipc.Node, orientation string) {
+	layout := ipc.SplitHLayout
+	switch orientation {
+	case "v":
+		layout = ipc.SplitVLayout
+	case "t":
+		if layoutOf(t.parent(target)) == ipc.SplitHLayout {
+			layout = ipc.SplitVLayout
+		}
+	}
+
+	if target.Type == ipc.WorkspaceNode {
+		setLayout(target, layout)
+		return
+	}
+
+	parent := t.parent(target)
+	if len(parent.Nodes) == 1 && (parent.Layout == ipc.SplitHLayout || parent.Layout == ipc.SplitVLayout) {
+		// like sway, splitting the only child of a split changes the split
+		setLayout(parent, layout)
+		return
+	}
+
+	con := t.newNode(ipc.ConNode, "")
+	setLayout(con, layout)
+	i := indexOf(parent.Nodes, target)
+	parent.Nodes[i] = con
+	con.Nodes = []*ipc.Node{target}
+	con.Focus = []int{target.ID}
+	for j, id := range parent.Focus {
+		if id == target.ID {
+			parent.Focus[j] = con.ID
+		}
+	}
+}
+
+func (t *Tree) focusCommand(target *ipc.Node, dir string) ipc.Command {
+	switch dir {
+	case "":
+		t.focusWindow(target)
+	case "parent":
+		if target.Type != ipc.WorkspaceNode {
+			t.focusWindow(t.parent(target))
+		}
+	case "child":
+		if len(target.Focus) > 0 {
+			t.focusWindow(t.byID(target.Focus[0]))
+		}
+	case "left", "right", "up", "down":
+		if next := t.neighbour(target, dir); next != nil {
+			t.focusWindow(t.focusInactive(next))
+		}
+	default:
+		return usage("focus <direction|parent|child>")
+	}
+
+	return success()
+}
+
+func (t *Tree) layoutCommand(target *ipc.Node, args []string) ipc.Command {
+	container := target
+	if target.Type != ipc.WorkspaceNode {
+		container = t.parent(target)
+	}
+
+	switch strings.Join(args, " ") {
+	case "splith":
+		setLayout(container, ipc.SplitHLayout)
+	case "splitv":
+		setLayout(container, ipc.SplitVLayout)
+	case "tabbed":
+		setLayout(container, ipc.TabbedLayout)
+	case "stacking":
+		setLayout(container, ipc.StackedLayout)
+	case "toggle split":
+		if layoutOf(container) == ipc.SplitHLayout {
+			setLayout(container, ipc.SplitVLayout)
+		} else {
+			setLayout(container, ipc.SplitHLayout)
+		}
+	default:
+		return usage("layout <splith|splitv|tabbed|stacking|toggle split>")
+	}
+
+	return success()
+}
+
+func (t *Tree) moveCommand(target *ipc.Node, args []string) ipc.Command {
+	if len(args) == 1 {
+		switch args[0] {
+		case "left", "right", "up", "down":
+			t.moveDirection(target, args[0])
+			return success()
+		}
+	}
+
+	// move [container|window] [to] workspace [number] <name>
+	for len(args) > 0 && (args[0] == "container" || args[0] == "window" || args[0] == "to") {
+		args = args[1:]
+	}
+
+	if len(args) < 2 || args[0] != "workspace" {
+		return usage("move <direction>|move container to workspace <name>")
+	}
+
+	name := args[1]
+	if name == "number" && len(args) > 2 {
+		name = args[2]
+	}
+
+	if !isWindow(target) {
+		return ipc.Command{Error: "Can only move windows in swaytest"}
+	}
+
+	dest := t.findWorkspace(name)
+	if dest == nil {
+		dest = t.newWorkspace(name)
+		t.add(t.outputOf(t.focused), dest, -1)
+		t.emit(ipc.WorkspaceEvent, ipc.WorkspaceChange{Change: ipc.InitWorkspace, Current: snapshot(dest)})
+	}
+
+	wasFocused := t.focused == target
+	parent := t.unlink(target)
+	t.add(dest, target, -1)
+	if wasFocused {
+		t.refocus(parent)
+	}
+
+	t.emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.MoveWindow, Container: *snapshot(target)})
+	t.considerDestroy(t.workspaceOf(parent))
+	return success()
+}
+
+func (t *Tree) moveDirection(target *ipc.Node, dir string) {
+	parent := t.parent(target)
+	if parent == nil || orientationOf(dir) != orientationOfLayout(layoutOf(parent)) {
+		return
+	}
+
+	i := indexOf(parent.Nodes, target)
+	j := i + step(dir)
+	if j < 0 || j >= len(parent.Nodes) {
+		return
+	}
+
+	parent.Nodes[i], parent.Nodes[j] = parent.Nodes[j], parent.Nodes[i]
+	t.emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.MoveWindow, Container: *snapshot(target)})
+}
+
+func (t *Tree) switchWorkspace(name string) ipc.Command {
+	if name == "" {
+		return usage("workspace [number] <name>")
+	}
+
+	old := t.workspaceOf(t.focused)
+	ws := t.findWorkspace(name)
+	if ws == old {
+		return success()
+	}
+
+	if ws == nil {
+		ws = t.newWorkspace(name)
+		t.add(t.outputOf(t.focused), ws, -1)
+		t.emit(ipc.WorkspaceEvent, ipc.WorkspaceChange{Change: ipc.InitWorkspace, Current: snapshot(ws)})
+	}
+
+	t.focusWindow(t.focusInactive(ws))
+	return success()
+}
+
+func (t *Tree) mark(target *ipc.Node, args []string) ipc.Command {
+	add, toggle := false, false
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		switch args[0] {
+		case "--add":
+			add = true
+		case "--toggle":
+			toggle = true
+		case "--replace":
+		default:
+			return usage("mark [--add|--replace] [--toggle] <identifier>")
+		}
+		args = args[1:]
+	}
+
+	if len(args) != 1 {
+		return usage("mark [--add|--replace] [--toggle] <identifier>")
+	}
+
+	mark := args[0]
+	has := contains(target.Marks, mark)
+
+	// a mark is unique in the tree
+	t.walk(t.root, func(n *ipc.Node) {
+		n.Marks = remove(n.Marks, mark)
+	})
+
+	switch {
+	case toggle && has:
+	case add:
+		target.Marks = append(target.Marks, mark)
+	default:
+		target.Marks = []string{mark}
+	}
+
+	t.emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.MarkWindow, Container: *snapshot(target)})
+	return success()
+}
+
+// focusWindow focuses n and sends the events for the change.
+func (t *Tree) focusWindow(n *ipc.Node) {
+	if n == nil || n == t.focused {
+		return
+	}
+
+	oldws := t.workspaceOf(t.focused)
+	t.focus(n)
+	ws := t.workspaceOf(n)
+
+	if ws != oldws {
+		t.emit(ipc.WorkspaceEvent, ipc.WorkspaceChange{Change: ipc.FocusWorkspace, Current: snapshot(ws), Old: snapshot(oldws)})
+		t.considerDestroy(oldws)
+	}
+
+	if n.Type != ipc.WorkspaceNode {
+		t.emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.FocusWindow, Container: *snapshot(n)})
+	}
+}
+
+// refocus focuses the most recently focused node below n, after the
+// focused window left the tree. The workspace does not change.
+func (t *Tree) refocus(n *ipc.Node) {
+	next := t.focusInactive(n)
+	t.focus(next)
+	if next.Type != ipc.WorkspaceNode {
+		t.emit(ipc.WindowEvent, ipc.WindowChange{Change: ipc.FocusWindow, Container: *snapshot(next)})
+	}
+}
+
+// considerDestroy removes ws, like sway, when it is empty and not focused.
+func (t *Tree) considerDestroy(ws *ipc.Node) {
+	if ws == nil || ws == t.workspaceOf(t.focused) || len(ws.Nodes) > 0 || len(ws.FloatingNodes) > 0 {
+		return
+	}
+
+	t.detach(ws)
+	t.emit(ipc.WorkspaceEvent, ipc.WorkspaceChange{Change: ipc.EmptyWorkspace, Current: snapshot(ws)})
+}
+
+// focus marks n as focused and moves it, and each of its ancestors,
+// to the front of the focus stack of its parent.
+func (t *Tree) focus(n *ipc.Node) {
+	if t.focused != nil {
+		t.focused.Focused = false
+	}
+
+	n.Focused = true
+	t.focused = n
+
+	for child, parent := n, t.parent(n); parent != nil; child, parent = parent, t.parent(parent) {
+		parent.Focus = append([]int{child.ID}, remove(parent.Focus, child.ID)...)
+	}
+}
+
+// focusInactive returns the node that gets focus when n is focused:
+// the most recently focused descendant of n.
+func (t *Tree) focusInactive(n *ipc.Node) *ipc.Node {
+	for len(n.Focus) > 0 {
+		next := t.byID(n.Focus[0])
+		if next == nil {
+			break
+		}
+		n = next
+	}
+
+	return n
+}
+
+// neighbour returns the container next to n in the direction,
+// looking for the closest ancestor split in that direction.
+func (t *Tree) neighbour(n *ipc.Node, dir string) *ipc.Node {
+	for child, parent := n, t.parent(n); parent != nil && parent.Type != ipc.OutputNode; child, parent = parent, t.parent(parent) {
+		if orientationOf(dir) != orientationOfLayout(layoutOf(parent)) {
+			continue
+		}
+
+		j := indexOf(parent.Nodes, child) + step(dir)
+		if j >= 0 && j < len(parent.Nodes) {
+			return parent.Nodes[j]
+		}
+	}
+
+	return nil
+}
+
+func (t *Tree) match(criteria string) ([]*ipc.Node, error) {
+	var c command.Criteria
+	for _, f := range fields(criteria) {
+		key, value, _ := strings.Cut(f, "=")
+		switch key {
+		case "app_id":
+			c.AppID = value
+		case "class":
+			c.Class = value
+		case "instance":
+			c.Instance = value
+		case "title":
+			c.Title = value
+		case "shell":
+			c.Shell = value
+		case "con_mark":
+			c.ConMark = value
+		case "workspace":
+			c.Workspace = value
+		case "con_id", "pid":
+			num, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %s", key, value)
+			}
+			if key == "con_id" {
+				c.ConID = num
+			} else {
+				c.Pid = num
+			}
+		case "floating":
+			c.Floating = true
+		case "tiling":
+			c.Tiling = true
+		case "urgent":
+			c.Urgent = command.Urgency(value)
+		default:
+			return nil, fmt.Errorf("criteria '%s' is not supported by swaytest", key)
+		}
+	}
+
+	pred, err := c.Predicate(t.root)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []*ipc.Node{}
+	t.walk(t.root, func(n *ipc.Node) {
+		if isWindow(n) || n.Type == ipc.ConNode && (c.ConID != 0 || c.ConMark != "") {
+			if pred(n) {
+				matched = append(matched, n)
+			}
+		}
+	})
+
+	return matched, nil
+}
+
+func (t *Tree) newNode(nt ipc.NodeType, name string) *ipc.Node {
+	n := &ipc.Node{ID: t.nextID, Name: name, Type: nt, Border: ipc.NoneBorder,
+		Nodes: []*ipc.Node{}, FloatingNodes: []*ipc.Node{}, Focus: []int{}, Marks: []string{}}
+	t.nextID++
+	return n
+}
+
+func (t *Tree) newWorkspace(name string) *ipc.Node {
+	ws := t.newNode(ipc.WorkspaceNode, name)
+	setLayout(ws, ipc.SplitHLayout)
+
+	num, err := strconv.Atoi(strings.SplitN(name, ":", 2)[0])
+	if err != nil {
+		num = -1
+	}
+
+	ws.Num = &num
+	return ws
+}
+
+// add inserts n into the children of parent at i, or at the end when i < 0.
+func (t *Tree) add(parent *ipc.Node, n *ipc.Node, i int) {
+	if i < 0 || i > len(parent.Nodes) {
+		i = len(parent.Nodes)
+	}
+
+	parent.Nodes = append(parent.Nodes, nil)
+	copy(parent.Nodes[i+1:], parent.Nodes[i:])
+	parent.Nodes[i] = n
+	parent.Focus = append(parent.Focus, n.ID)
+
+	if n.Type == ipc.WorkspaceNode {
+		output := parent.Name
+		n.Output = &output
+	}
+}
+
+func (t *Tree) detach(n *ipc.Node) {
+	parent := t.parent(n)
+	if parent == nil {
+		return
+	}
+
+	parent.Nodes = append(parent.Nodes[:0:0], parent.Nodes...)
+	parent.Nodes = append(parent.Nodes[:indexOf(parent.Nodes, n)], parent.Nodes[indexOf(parent.Nodes, n)+1:]...)
+	parent.Focus = remove(parent.Focus, n.ID)
+}
+
+func (t *Tree) parent(n *ipc.Node) *ipc.Node {
+	var found *ipc.Node
+	t.walk(t.root, func(p *ipc.Node) {
+		if found == nil && indexOf(p.Nodes, n) >= 0 {
+			found = p
+		}
+	})
+
+	return found
+}
+
+func (t *Tree) isAncestor(a *ipc.Node, n *ipc.Node) bool {
+	for p := t.parent(n); p != nil; p = t.parent(p) {
+		if p == a {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *Tree) workspaceOf(n *ipc.Node) *ipc.Node {
+	for ; n != nil; n = t.parent(n) {
+		if n.Type == ipc.WorkspaceNode {
+			return n
+		}
+	}
+
+	return nil
+}
+
+func (t *Tree) outputOf(n *ipc.Node) *ipc.Node {
+	for ; n != nil; n = t.parent(n) {
+		if n.Type == ipc.OutputNode {
+			return n
+		}
+	}
+
+	return nil
+}
+
+func (t *Tree) findWorkspace(name string) *ipc.Node {
+	var found *ipc.Node
+	t.walk(t.root, func(n *ipc.Node) {
+		if found == nil && n.Type == ipc.WorkspaceNode && n.Name == name {
+			found = n
+		}
+	})
+
+	return found
+}
+
+func (t *Tree) byID(id int) *ipc.Node {
+	var found *ipc.Node
+	t.walk(t.root, func(n *ipc.Node) {
+		if n.ID == id {
+			found = n
+		}
+	})
+
+	return found
+}
+
+func (t *Tree) walk(n *ipc.Node, f func(*ipc.Node)) {
+	f(n)
+	for _, child := range n.Nodes {
+		t.walk(child, f)
+	}
+	for _, child := range n.FloatingNodes {
+		t.walk(child, f)
+	}
+}
+
+func (t *Tree) emit(ept ipc.EventPayloadType, args any) {
+	t.pending = append(t.pending, event{ept, args})
+}
+
+func (t *Tree) flush() []event {
+	events := t.pending
+	t.pending = nil
+	if t.onEvent == nil {
+		return nil
+	}
+
+	return events
+}
+
+func (t *Tree) dispatch(events []event) {
+	t.mx.Lock()
+	onEvent := t.onEvent
+	t.mx.Unlock()
+
+	for _, e := range events {
+		onEvent(e.ept, e.args)
+	}
+}
+
+func isWindow(n *ipc.Node) bool {
+	return n != nil && (n.Type == ipc.ConNode || n.Type == ipc.FloatingConNode) && len(n.Nodes) == 0 && n.AppID != nil
+}
+
+func setLayout(n *ipc.Node, l ipc.LayoutType) {
+	n.Layout = l
+	n.Orientation = orientationOfLayout(l)
+}
+
+func layoutOf(n *ipc.Node) ipc.LayoutType {
+	if n == nil {
+		return ""
+	}
+
+	return n.Layout
+}
+
+func orientationOfLayout(l ipc.LayoutType) ipc.OrientationType {
+	switch l {
+	case ipc.SplitHLayout, ipc.TabbedLayout:
+		return ipc.HorizontalOrientation
+	case ipc.SplitVLayout, ipc.StackedLayout:
+		return ipc.VerticalOrientation
+	}
+
+	return ipc.NoneOrientation
+}
+
+func orientationOf(dir string) ipc.OrientationType {
+	if dir == "left" || dir == "right" {
+		return ipc.HorizontalOrientation
+	}
+
+	return ipc.VerticalOrientation
+}
+
+func step(dir string) int {
+	if dir == "left" || dir == "up" {
+		return -1
+	}
+
+	return 1
+}
+
+func shape(n *ipc.Node) string {
+	if len(n.Nodes) == 0 && n.AppID != nil {
+		return *n.AppID
+	}
+
+	prefix := map[ipc.LayoutType]string{
+		ipc.SplitHLayout:  "H",
+		ipc.SplitVLayout:  "V",
+		ipc.TabbedLayout:  "T",
+		ipc.StackedLayout: "S",
+	}[n.Layout]
+
+	children := make([]string, len(n.Nodes))
+	for i, child := range n.Nodes {
+		children[i] = shape(child)
+	}
+
+	return prefix + "[" + strings.Join(children, " ") + "]"
+}
+
+func snapshot(n *ipc.Node) *ipc.Node {
+	if n == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(n)
+	if err != nil {
+		panic(err)
+	}
+
+	c := new(ipc.Node)
+	if err := json.Unmarshal(b, c); err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+func indexOf(nodes []*ipc.Node, n *ipc.Node) int {
+	for i, c := range nodes {
+		if c == n {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func contains[T comparable](ts []T, t T) bool {
+	for _, v := range ts {
+		if v == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+func remove[T comparable](ts []T, t T) []T {
+	out := make([]T, 0, len(ts))
+	for _, v := range ts {
+		if v != t {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
+
+func success() ipc.Command {
+	return ipc.Command{Result: ipc.Result{Success: true}}
+}
+
+func parseError(cmd string) ipc.Command {
+	return ipc.Command{ParseError: true, Error: fmt.Sprintf("Unknown/invalid command '%s'", cmd)}
+}
+
+func usage(expected string) ipc.Command {
+	return ipc.Command{Error: "Expected " + expected}
+}
+
+// fields splits a command into arguments on whitespace,
+// removing quotes around arguments.
+func fields(cmd string) []string {
+	var args []string
+	var b strings.Builder
+	var quote rune
+	inArg, escaped := false, false
+
+	for _, r := range cmd {
+		switch {
+		case escaped:
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				b.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, b.String())
+				b.Reset()
+				inArg = false
+			}
+		default:
+			b.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, b.String())
+	}
+
+	return args
+}
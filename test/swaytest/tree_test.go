@@ -0,0 +1,174 @@
+package swaytest_test
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/libanvl/swager/ipc"
+	"github.com/libanvl/swager/test/swaytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreeCommands(t *testing.T) {
+	cases := map[string]struct {
+		windows []string
+		cmd     string
+		shape   string
+		focused string
+	}{
+		"open":             {[]string{"a", "b", "c"}, "nop", "H[a b c]", "c"},
+		"split only child": {[]string{"a"}, "splitv", "V[a]", "a"},
+		"split":            {[]string{"a", "b"}, "splitv", "H[a V[b]]", "b"},
+		"layout":           {[]string{"a", "b"}, "layout tabbed", "T[a b]", "b"},
+		"toggle split":     {[]string{"a", "b"}, "layout toggle split", "V[a b]", "b"},
+		"focus left":       {[]string{"a", "b"}, "focus left", "H[a b]", "a"},
+		"focus up noop":    {[]string{"a", "b"}, "focus up", "H[a b]", "b"},
+		"move left":        {[]string{"a", "b", "c"}, "move left", "H[a c b]", "c"},
+		"criteria":         {[]string{"a", "b"}, "[app_id=a] focus", "H[a b]", "a"},
+		"kill":             {[]string{"a", "b"}, "kill", "H[a]", "a"},
+		"focus parent":     {[]string{"a", "b"}, "splitv; focus parent; focus left", "H[a V[b]]", "a"},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			tree := swaytest.NewTree("eDP-1")
+			for _, w := range c.windows {
+				tree.OpenWindow(w)
+			}
+
+			for _, res := range tree.Apply(c.cmd) {
+				require.True(t, res.Success, res.Error)
+			}
+
+			assert.Equal(t, c.shape, tree.Shape("1"))
+			assert.Equal(t, c.focused, *tree.Focused().AppID)
+		})
+	}
+}
+
+func TestTreeWorkspaces(t *testing.T) {
+	tree := swaytest.NewTree("eDP-1", "HDMI-A-1")
+	a := tree.OpenWindow("a")
+
+	res := tree.Apply("workspace 3; move container to workspace 1")
+	require.Len(t, res, 2)
+	assert.False(t, res[1].Success)
+
+	res = tree.Apply("workspace number 1; move container to workspace number 3")
+	for _, r := range res {
+		assert.True(t, r.Success, r.Error)
+	}
+
+	wss := tree.Workspaces()
+	require.Len(t, wss, 3)
+	assert.Equal(t, "1", wss[0].Name)
+	assert.True(t, wss[0].Focused)
+	assert.Equal(t, 3, wss[1].Num)
+	assert.False(t, wss[1].Visible)
+	assert.Equal(t, "2", wss[2].Name)
+	assert.Equal(t, "HDMI-A-1", wss[2].Output)
+	assert.True(t, wss[2].Visible)
+	assert.Equal(t, "H[a]", tree.Shape("3"))
+
+	assert.Nil(t, tree.CloseWindow(a))
+	assert.NotNil(t, tree.CloseWindow(a))
+	assert.Len(t, tree.Workspaces(), 2)
+}
+
+func TestTreeWithoutOutputs(t *testing.T) {
+	tree := swaytest.NewTree()
+	assert.Equal(t, 0, tree.OpenWindow("a"))
+	assert.Nil(t, tree.Focused())
+}
+
+func TestTreeMarks(t *testing.T) {
+	tree := swaytest.NewTree("eDP-1")
+	tree.OpenWindow("a")
+	tree.OpenWindow("b")
+
+	tree.Apply("mark x; [app_id=a] mark --add y, mark --add x")
+	assert.Equal(t, []string{"y", "x"}, tree.Marks())
+
+	tree.Apply("[con_mark=x] focus; unmark y")
+	assert.Equal(t, "a", *tree.Focused().AppID)
+	assert.Equal(t, []string{"x"}, tree.Marks())
+
+	res := tree.Apply("[con_mark=y] kill")
+	assert.False(t, res[0].Success)
+
+	odd := tree.OpenWindow("a]b")
+	res = tree.Apply(`[app_id="a]b"] mark odd`)
+	require.True(t, res[0].Success, res[0].Error)
+	assert.Equal(t, odd, tree.Focused().ID)
+	assert.Contains(t, tree.Marks(), "odd")
+
+	res = tree.Apply("frobnicate")
+	assert.True(t, res[0].ParseError)
+}
+
+func TestServerSimulate(t *testing.T) {
+	server := swaytest.NewServer(t)
+	tree := swaytest.NewTree("eDP-1")
+	server.Simulate(tree)
+
+	var execs []string
+	tree.ExecHook = func(cmdline string) {
+		execs = append(execs, cmdline)
+		tree.OpenWindow(cmdline)
+	}
+
+	sc, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+	require.Nil(t, err)
+	sub := ipc.SubscribeCustom(sc)
+	defer sub.Close()
+
+	windows := make(chan ipc.WindowChange, 10)
+	workspaces := make(chan ipc.WorkspaceChange, 10)
+	_, err = sub.WindowChanges(func(wc ipc.WindowChange) { windows <- wc })
+	require.Nil(t, err)
+	_, err = sub.WorkspaceChanges(func(wc ipc.WorkspaceChange) { workspaces <- wc })
+	require.Nil(t, err)
+	go sub.Run()
+
+	client, err := ipc.ConnectCustom(server.Path(), binary.LittleEndian)
+	require.Nil(t, err)
+	defer client.Close()
+
+	err = client.CommandChecked("workspace 2, exec foot")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"foot"}, execs)
+
+	// handlers run concurrently, so events can arrive in any order
+	var wschanges []ipc.WorkspaceChangeType
+	for len(wschanges) < 3 {
+		select {
+		case wc := <-workspaces:
+			wschanges = append(wschanges, wc.Change)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for workspace events, got %v", wschanges)
+		}
+	}
+	assert.ElementsMatch(t, []ipc.WorkspaceChangeType{ipc.InitWorkspace, ipc.FocusWorkspace, ipc.EmptyWorkspace}, wschanges)
+
+	var winchanges []ipc.WindowChangeType
+	for len(winchanges) < 2 {
+		select {
+		case wc := <-windows:
+			winchanges = append(winchanges, wc.Change)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for window events, got %v", winchanges)
+		}
+	}
+	assert.ElementsMatch(t, []ipc.WindowChangeType{ipc.NewWindow, ipc.FocusWindow}, winchanges)
+
+	root, err := client.Tree()
+	require.Nil(t, err)
+	assert.Equal(t, "eDP-1", root.Nodes[0].Name)
+
+	wss, err := client.Workspaces()
+	require.Nil(t, err)
+	require.Len(t, wss, 1)
+	assert.Equal(t, "2", wss[0].Name)
+}